@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Send this to a running daemon to change wallpapers right away, e.g. from a keybinding:
+//
+//	pkill -USR1 set-wallpaper
+const WALLPAPER_REFRESH_SIGNAL = syscall.SIGUSR1
+
+// Sends on the returned channel whenever outputs are added, removed or changed
+func subscribeOutputEvents() <-chan struct{} {
+	events := make(chan struct{}, 1)
+
+	subscription, err := swaySubscribe("output")
+	if err != nil {
+		fmt.Println("Could not subscribe to output events", err)
+		return events
+	}
+
+	go func() {
+		defer subscription.Close()
+		for {
+			_, _, err := subscription.ReadEvent()
+			if err != nil {
+				fmt.Println("Output event subscription closed", err)
+				return
+			}
+
+			select {
+			case events <- struct{}{}:
+			default: // Already pending
+			}
+		}
+	}()
+
+	return events
+}
+
+// Changes every output's wallpaper each interval and whenever WALLPAPER_REFRESH_SIGNAL is
+// received. Outputs that appear while running are given a wallpaper straight away. Errors are
+// printed and the daemon carries on, e.g. when sway is restarting.
+func runDaemon(interval time.Duration, wallpapers []string, state wallpaperState, rng *rand.Rand, opts Options) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, WALLPAPER_REFRESH_SIGNAL)
+
+	outputEvents := subscribeOutputEvents()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	knownOutputs := make(map[string]bool)
+	if err := setNewOutputWallpapers(knownOutputs, wallpapers, state, rng, opts); err != nil {
+		fmt.Println(err)
+	}
+	saveWallpaperState(state)
+
+	for {
+		var err error
+		select {
+		case <-ticker.C:
+			err = changeWallpapers(wallpapers, state, rng, opts)
+
+		case <-signals:
+			err = changeWallpapers(wallpapers, state, rng, opts)
+			// Start a full interval from the manual change
+			ticker.Reset(interval)
+
+		case <-outputEvents:
+			err = setNewOutputWallpapers(knownOutputs, wallpapers, state, rng, opts)
+		}
+
+		if err != nil {
+			fmt.Println(err)
+		}
+		saveWallpaperState(state)
+	}
+}
+
+func changeWallpapers(wallpapers []string, state wallpaperState, rng *rand.Rand, opts Options) error {
+	outputs, err := getActiveOutputs()
+	if err != nil {
+		return fmt.Errorf("could not get the outputs from sway: %w", err)
+	}
+	return setRandomWallpapers(outputs, wallpapers, state, rng, opts)
+}
+
+// Gives a wallpaper to the outputs that aren't in knownOutputs yet, and adds them to it
+func setNewOutputWallpapers(knownOutputs map[string]bool, wallpapers []string, state wallpaperState, rng *rand.Rand, opts Options) error {
+	outputs, err := getActiveOutputs()
+	if err != nil {
+		return fmt.Errorf("could not get the outputs from sway: %w", err)
+	}
+
+	// With -single, new outputs join in with the wallpaper the others already share
+	shared := ""
+	if opts.Single {
+		shared = sharedWallpaper(state, knownOutputs)
+	}
+
+	newOutputs := []Screen{}
+	for _, output := range outputs {
+		if !knownOutputs[output.Name] {
+			knownOutputs[output.Name] = true
+			newOutputs = append(newOutputs, output)
+		}
+	}
+
+	if shared != "" && len(newOutputs) > 0 {
+		return setSharedWallpaper(newOutputs, shared, state, opts)
+	}
+	return setRandomWallpapers(newOutputs, wallpapers, state, rng, opts)
+}
+
+// Puts the wallpaper that the other outputs share on new ones
+func setSharedWallpaper(outputs []Screen, wallpaper string, state wallpaperState, opts Options) error {
+	img, err := loadWallpaper(wallpaper)
+	if err != nil {
+		return err
+	}
+
+	commands := swayCommands{}
+	defer commands.run()
+
+	errs := []error{}
+	for _, output := range outputs {
+		err := setWallpaperImageForScreen(&commands, output, wallpaper, img, opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		state[output.Name] = wallpaper
+	}
+	return errors.Join(errs...)
+}
+
+// The wallpaper of any output that already has one that still exists
+func sharedWallpaper(state wallpaperState, knownOutputs map[string]bool) string {
+	for name := range knownOutputs {
+		if wallpaper, exists := state[name]; exists {
+			if _, err := os.Stat(wallpaper); err == nil {
+				return wallpaper
+			}
+		}
+	}
+	return ""
+}
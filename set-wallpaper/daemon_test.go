@@ -0,0 +1,155 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"swayipc"
+)
+
+// Replies to IPC_GET_OUTPUTS with whatever outputs is set to and records every command
+type fakeSwayOutputs struct {
+	lock     sync.Mutex
+	outputs  string
+	commands []string
+}
+
+func startFakeSwayOutputs(t *testing.T, outputs string) *fakeSwayOutputs {
+	t.Helper()
+
+	fake := &fakeSwayOutputs{outputs: outputs}
+	startFakeSway(t, func(msgType uint32, payload []byte) []byte {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		switch msgType {
+		case swayipc.IPC_GET_OUTPUTS:
+			return []byte(fake.outputs)
+		case swayipc.IPC_COMMAND:
+			fake.commands = append(fake.commands, string(payload))
+			return []byte(`[{"success":true}]`)
+		}
+		return []byte(`{"success":false}`)
+	})
+	return fake
+}
+
+func (fake *fakeSwayOutputs) setOutputs(outputs string) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.outputs = outputs
+}
+
+func (fake *fakeSwayOutputs) takeCommands() []string {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	commands := fake.commands
+	fake.commands = nil
+	return commands
+}
+
+const (
+	oneOutput    = `[{"name":"DP-1","active":true,"scale":1,"current_mode":{"width":48,"height":48}},{"name":"HDMI-A-1","active":false}]`
+	twoOutputs   = `[{"name":"DP-1","active":true,"scale":1,"current_mode":{"width":48,"height":48}},{"name":"DP-2","active":true,"scale":1,"current_mode":{"width":96,"height":32}}]`
+	brokenOutput = `not json`
+)
+
+func writeTestWallpaper(t *testing.T, name string, contents []byte) string {
+	t.Helper()
+	wallpaper := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(wallpaper, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return wallpaper
+}
+
+func TestSetNewOutputWallpapers(t *testing.T) {
+	fake := startFakeSwayOutputs(t, oneOutput)
+	wallpaper := writeTestWallpaper(t, "wallpaper.png", testWallpaperPNG(t))
+	chdirTemp(t)
+
+	state := wallpaperState{}
+	knownOutputs := make(map[string]bool)
+	rng := rand.New(rand.NewSource(1))
+
+	err := setNewOutputWallpapers(knownOutputs, []string{wallpaper}, state, rng, defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state["DP-1"] != wallpaper || len(state) != 1 {
+		t.Errorf("State is %v", state)
+	}
+	if commands := fake.takeCommands(); len(commands) != 1 || !strings.Contains(commands[0], `output "DP-1" bg`) {
+		t.Errorf("Sent %q", commands)
+	}
+
+	// Nothing new
+	err = setNewOutputWallpapers(knownOutputs, []string{wallpaper}, state, rng, defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commands := fake.takeCommands(); len(commands) != 0 {
+		t.Errorf("Sent %q without a new output", commands)
+	}
+
+	fake.setOutputs(twoOutputs)
+	err = setNewOutputWallpapers(knownOutputs, []string{wallpaper}, state, rng, defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := fake.takeCommands()
+	if len(commands) != 1 || !strings.Contains(commands[0], `output "DP-2" bg`) || strings.Contains(commands[0], "DP-1") {
+		t.Errorf("Sent %q, want only DP-2", commands)
+	}
+}
+
+// Every failure is returned for the daemon to print, rather than exiting
+func TestDaemonErrors(t *testing.T) {
+	fake := startFakeSwayOutputs(t, brokenOutput)
+	wallpaper := writeTestWallpaper(t, "wallpaper.png", testWallpaperPNG(t))
+	broken := writeTestWallpaper(t, "broken.png", []byte("not an image"))
+	chdirTemp(t)
+
+	rng := rand.New(rand.NewSource(1))
+	state := wallpaperState{}
+	knownOutputs := make(map[string]bool)
+
+	if err := changeWallpapers([]string{wallpaper}, state, rng, defaultOptions()); err == nil {
+		t.Error("Expected an error when sway's outputs can't be read")
+	}
+	if err := setNewOutputWallpapers(knownOutputs, []string{wallpaper}, state, rng, defaultOptions()); err == nil {
+		t.Error("Expected an error when sway's outputs can't be read")
+	}
+	if len(knownOutputs) != 0 {
+		t.Errorf("Outputs %v became known", knownOutputs)
+	}
+
+	fake.setOutputs(twoOutputs)
+	err := changeWallpapers([]string{broken}, state, rng, defaultOptions())
+	if err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("Got error %v", err)
+	}
+	if len(state) != 0 {
+		t.Errorf("Broken wallpaper was recorded in %v", state)
+	}
+	if commands := fake.takeCommands(); len(commands) != 0 {
+		t.Errorf("Sent %q", commands)
+	}
+
+	// The same wallpaper can still go on the other outputs after a failure
+	opts := defaultOptions()
+	opts.Single = true
+	if err := setSharedWallpaper([]Screen{testScreen("DP-1", 48, 48)}, broken, state, opts); err == nil {
+		t.Error("Expected an error for a shared wallpaper that can't be decoded")
+	}
+	if err := changeWallpapers([]string{wallpaper}, state, rng, opts); err != nil {
+		t.Fatal(err)
+	}
+	if state["DP-1"] != wallpaper || state["DP-2"] != wallpaper {
+		t.Errorf("State is %v", state)
+	}
+}
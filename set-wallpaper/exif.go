@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+
+	"github.com/disintegration/gift"
+)
+
+const exifOrientationTag = 0x0112
+
+// Reads the EXIF orientation (1-8) of a JPEG. Returns 1 (no transform) if there is no orientation tag.
+func readJPEGOrientation(r io.Reader) int {
+	reader := bufio.NewReader(r)
+
+	var soi [2]byte
+	if _, err := io.ReadFull(reader, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return 1
+	}
+
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(reader, marker[:]); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+
+		// Start of scan means there are no more metadata segments
+		if marker[1] == 0xDA {
+			return 1
+		}
+
+		segmentLength := int(binary.BigEndian.Uint16(marker[2:4])) - 2
+		if segmentLength < 0 {
+			return 1
+		}
+
+		segment := make([]byte, segmentLength)
+		if _, err := io.ReadFull(reader, segment); err != nil {
+			return 1
+		}
+
+		// APP1 holds the EXIF data
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFFOrientation(segment[6:])
+		}
+	}
+}
+
+func parseTIFFOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var byteOrder binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		byteOrder = binary.LittleEndian
+	case "MM":
+		byteOrder = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifdOffset := int(byteOrder.Uint32(tiff[4:8]))
+	if ifdOffset+2 > len(tiff) {
+		return 1
+	}
+
+	entryCount := int(byteOrder.Uint16(tiff[ifdOffset : ifdOffset+2]))
+	for i := 0; i < entryCount; i++ {
+		entryStart := ifdOffset + 2 + i*12
+		if entryStart+12 > len(tiff) {
+			return 1
+		}
+
+		entry := tiff[entryStart : entryStart+12]
+		if byteOrder.Uint16(entry[0:2]) == exifOrientationTag {
+			orientation := int(byteOrder.Uint16(entry[8:10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+// Rotates and flips the image so that it is displayed upright according to its EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	var filter gift.Filter
+	switch orientation {
+	case 2:
+		filter = gift.FlipHorizontal()
+	case 3:
+		filter = gift.Rotate180()
+	case 4:
+		filter = gift.FlipVertical()
+	case 5:
+		filter = gift.Transpose()
+	case 6:
+		filter = gift.Rotate270()
+	case 7:
+		filter = gift.Transverse()
+	case 8:
+		filter = gift.Rotate90()
+	default:
+		return img
+	}
+
+	g := gift.New(filter)
+	result := image.NewRGBA(g.Bounds(img.Bounds()))
+	g.Draw(result, img)
+	return result
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"testing"
+)
+
+type corner int
+
+const (
+	topLeft corner = iota
+	topRight
+	bottomLeft
+	bottomRight
+)
+
+// The colors of the corners of the upright wallpaper
+var uprightColors = map[corner]color.RGBA{
+	topLeft:     {255, 0, 0, 255},
+	topRight:    {0, 255, 0, 255},
+	bottomLeft:  {0, 0, 255, 255},
+	bottomRight: {255, 255, 255, 255},
+}
+
+// Where each corner of the stored image ends up once it is displayed, for each EXIF orientation
+var displayedCorners = map[int]map[corner]corner{
+	1: {topLeft: topLeft, topRight: topRight, bottomLeft: bottomLeft, bottomRight: bottomRight},
+	2: {topLeft: topRight, topRight: topLeft, bottomLeft: bottomRight, bottomRight: bottomLeft},
+	3: {topLeft: bottomRight, topRight: bottomLeft, bottomLeft: topRight, bottomRight: topLeft},
+	4: {topLeft: bottomLeft, topRight: bottomRight, bottomLeft: topLeft, bottomRight: topRight},
+	5: {topLeft: topLeft, topRight: bottomLeft, bottomLeft: topRight, bottomRight: bottomRight},
+	6: {topLeft: topRight, topRight: bottomRight, bottomLeft: topLeft, bottomRight: bottomLeft},
+	7: {topLeft: bottomRight, topRight: topRight, bottomLeft: bottomLeft, bottomRight: topLeft},
+	8: {topLeft: bottomLeft, topRight: topLeft, bottomLeft: bottomRight, bottomRight: topRight},
+}
+
+func quadrant(bounds image.Rectangle, which corner) image.Rectangle {
+	center := image.Pt(bounds.Dx()/2, bounds.Dy()/2)
+	switch which {
+	case topLeft:
+		return image.Rect(0, 0, center.X, center.Y)
+	case topRight:
+		return image.Rect(center.X, 0, bounds.Dx(), center.Y)
+	case bottomLeft:
+		return image.Rect(0, center.Y, center.X, bounds.Dy())
+	}
+	return image.Rect(center.X, center.Y, bounds.Dx(), bounds.Dy())
+}
+
+// A JPEG stored so that it is upright once the orientation is applied. A nil byte order leaves
+// out the EXIF segment.
+func orientedJPEG(t *testing.T, orientation int, byteOrder binary.ByteOrder) []byte {
+	t.Helper()
+
+	// Upright is 64 by 32, orientations 5 to 8 turn the image on its side
+	width, height := 64, 32
+	if orientation >= 5 {
+		width, height = height, width
+	}
+
+	stored := image.NewRGBA(image.Rect(0, 0, width, height))
+	for storedCorner, displayedCorner := range displayedCorners[orientation] {
+		draw.Draw(stored, quadrant(stored.Bounds(), storedCorner), image.NewUniform(uprightColors[displayedCorner]), image.Point{}, draw.Src)
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, stored, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if byteOrder == nil {
+		return encoded.Bytes()
+	}
+
+	// A TIFF header and a single IFD with only the orientation
+	tiff := make([]byte, 8+2+12+4)
+	if byteOrder == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	byteOrder.PutUint16(tiff[2:], 42)
+	byteOrder.PutUint32(tiff[4:], 8)
+	byteOrder.PutUint16(tiff[8:], 1)
+	entry := tiff[10:22]
+	byteOrder.PutUint16(entry[0:], exifOrientationTag)
+	byteOrder.PutUint16(entry[2:], 3) // SHORT
+	byteOrder.PutUint32(entry[4:], 1)
+	byteOrder.PutUint16(entry[8:], uint16(orientation))
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(app1)+2))
+	segment = append(segment, app1...)
+
+	// Right after the start of image marker
+	jpegBytes := encoded.Bytes()
+	result := append([]byte{}, jpegBytes[:2]...)
+	result = append(result, segment...)
+	return append(result, jpegBytes[2:]...)
+}
+
+func closeTo(got color.Color, want color.RGBA) bool {
+	r, g, b, _ := got.RGBA()
+	near := func(channel uint32, wanted uint8) bool {
+		difference := int(channel>>8) - int(wanted)
+		return difference > -40 && difference < 40
+	}
+	return near(r, want.R) && near(g, want.G) && near(b, want.B)
+}
+
+func assertUpright(t *testing.T, img image.Image) {
+	t.Helper()
+
+	bounds := img.Bounds()
+	if bounds.Dx() != 64 || bounds.Dy() != 32 {
+		t.Fatalf("Image is %dx%d, want 64x32", bounds.Dx(), bounds.Dy())
+	}
+
+	for which, want := range uprightColors {
+		area := quadrant(bounds, which)
+		center := image.Pt(bounds.Min.X+(area.Min.X+area.Max.X)/2, bounds.Min.Y+(area.Min.Y+area.Max.Y)/2)
+		if got := img.At(center.X, center.Y); !closeTo(got, want) {
+			t.Errorf("Corner %d is %v, want %v", which, got, want)
+		}
+	}
+}
+
+func TestReadJPEGOrientation(t *testing.T) {
+	for orientation := 1; orientation <= 8; orientation++ {
+		for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			data := orientedJPEG(t, orientation, byteOrder)
+			if got := readJPEGOrientation(bytes.NewReader(data)); got != orientation {
+				t.Errorf("Orientation %d in %v read as %d", orientation, byteOrder, got)
+			}
+		}
+	}
+
+	if got := readJPEGOrientation(bytes.NewReader(orientedJPEG(t, 1, nil))); got != 1 {
+		t.Errorf("JPEG without EXIF read as %d, want 1", got)
+	}
+	if got := readJPEGOrientation(bytes.NewReader([]byte("not an image"))); got != 1 {
+		t.Errorf("Garbage read as %d, want 1", got)
+	}
+}
+
+func TestDecodeWallpaperAppliesOrientation(t *testing.T) {
+	for orientation := 1; orientation <= 8; orientation++ {
+		t.Run(strconv.Itoa(orientation), func(t *testing.T) {
+			img, err := decodeWallpaper(bytes.NewReader(orientedJPEG(t, orientation, binary.LittleEndian)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertUpright(t, img)
+		})
+	}
+}
+
+// Only JPEGs have their orientation read, anything else is used as it is stored
+func TestDecodeWallpaperLeavesPNGAlone(t *testing.T) {
+	upright := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for which, fill := range uprightColors {
+		draw.Draw(upright, quadrant(upright.Bounds(), which), image.NewUniform(fill), image.Point{}, draw.Src)
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, upright); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := decodeWallpaper(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUpright(t, img)
+}
@@ -4,3 +4,7 @@ go 1.22.6
 
 require golang.org/x/exp v0.0.0-20241217172543-b2144cdd0a67
 require github.com/disintegration/gift v1.2.1
+require swayipc v0.0.0
+
+// Shared with the other tools in the repo
+replace swayipc => ../swayipc
@@ -7,24 +7,32 @@ package main
 //   - wallpapers directory
 
 import (
-	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"image"
-	// "image/color"
+	"image/color"
+	_ "image/gif" // image.Decode only returns the first frame of an animated GIF
+	_ "image/jpeg"
 	"image/png"
+	"io"
+	"log"
 	"math/rand"
-	"net"
 	"os"
 	"path"
 	"strings"
 	"time"
-	"unsafe"
 
 	"github.com/disintegration/gift"
 	"golang.org/x/exp/slices"
+
+	"swayipc"
 )
 
+// Geometry and progress details, only shown with -v
+var verbose = log.New(io.Discard, "", 0)
+
 func swap[T any](first, second *T) {
 	temp := *first
 	*first = *second
@@ -37,127 +45,106 @@ func ensureDirExists(dir string) {
 	}
 }
 
-type messageType int
-
-// Basic messages
-const (
-	IPC_COMMAND   = 0
-	IPC_SUBSCRIBE = 2
-	IPC_SEND_TICK = 10
-	IPC_SYNC      = 11
-)
-
-// Queries
-const (
-	IPC_GET_WORKSPACES    = 1
-	IPC_GET_OUTPUTS       = 3
-	IPC_GET_TREE          = 4
-	IPC_GET_MARKS         = 5
-	IPC_GET_BAR_CONFIG    = 6
-	IPC_GET_VERSION       = 7
-	IPC_GET_BINDING_MODES = 8
-	IPC_GET_CONFIG        = 9
-	IPC_GET_BINDING_STATE = 12
-
-	/* sway-specific command types */
-	IPC_GET_INPUTS = 100
-	IPC_GET_SEATS  = 101
-)
-
-// Events
-const (
-	IPC_EVENT_WORKSPACE        = ((1 << 31) | 0)
-	IPC_EVENT_OUTPUT           = ((1 << 31) | 1)
-	IPC_EVENT_MODE             = ((1 << 31) | 2)
-	IPC_EVENT_WINDOW           = ((1 << 31) | 3)
-	IPC_EVENT_BARCONFIG_UPDATE = ((1 << 31) | 4)
-	IPC_EVENT_BINDING          = ((1 << 31) | 5)
-	IPC_EVENT_SHUTDOWN         = ((1 << 31) | 6)
-	IPC_EVENT_TICK             = ((1 << 31) | 7)
-
-	/* sway-specific event types */
-	IPC_EVENT_BAR_STATE_UPDATE = ((1 << 31) | 20)
-	IPC_EVENT_INPUT            = ((1 << 31) | 21)
-)
-
-func swayMsgCommand(msgType messageType, payload string) []byte {
-	const i3MagicString = "i3-ipc"
-	const IPC_HEADER_SIZE = (uintptr(len(i3MagicString)) + 2*unsafe.Sizeof(int32(0)))
+func swayMsgCommand(msgType int, payload string) ([]byte, error) {
+	var client swayipc.Client
+	err := client.Dial()
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to sway: %w", err)
+	}
+	defer client.Close()
 
-	socketPath := os.Getenv("SWAYSOCK")
-	connection, err := net.Dial("unix", socketPath)
+	response, err := client.Send(msgType, payload)
 	if err != nil {
-		fmt.Println("Unable to create connection", err)
-		return []byte{}
+		return nil, fmt.Errorf("error when reading response: %w", err)
 	}
 
-	length := uint32(len(payload))
-	var lengthAndType [8]byte
-	binary.LittleEndian.PutUint32(lengthAndType[0:4], length)
-	binary.LittleEndian.PutUint32(lengthAndType[4:8], uint32(msgType))
-	message := append([]byte(i3MagicString), lengthAndType[:]...)
-	connection.Write(message)
-	connection.Write([]byte(payload))
+	return response, nil
+}
 
-	responseHeader := make([]byte, IPC_HEADER_SIZE)
-	_, err = connection.Read(responseHeader)
+// Opens a connection that receives the given events, e.g. "output"
+func swaySubscribe(events ...string) (*swayipc.Client, error) {
+	client := &swayipc.Client{}
+	err := client.Dial()
 	if err != nil {
-		fmt.Println("Error when reading response header", err)
-		return []byte{}
+		return nil, err
 	}
 
-	responseLength := binary.LittleEndian.Uint32(responseHeader[len(i3MagicString) : len(i3MagicString)+4])
-	// responseType := binary.LittleEndian.Uint32(responseHeader[len(i3MagicString)+4:])
-
-	response := make([]byte, responseLength)
-	_, err = connection.Read(response)
+	err = client.Subscribe(events...)
 	if err != nil {
-		fmt.Println("Error when reading response payload", err)
-		return []byte{}
+		client.Close()
+		return nil, err
 	}
 
-	return response
+	return client, nil
 }
 
-// type SwayTreeJSON struct {
-// 	Dimensions struct {
-// 		Height int `json:"height"`
-// 		Width  int `json:"width"`
-// 	} `json:"rect"`
-// }
-//
-// func getScreenDimensionsSway() (int, int) {
-// 	jsonBytes := swayMsgCommand(IPC_GET_TREE, "")
-//
-// 	var swayTreeJson SwayTreeJSON
-// 	err := json.Unmarshal(jsonBytes, &swayTreeJson)
-// 	if err != nil {
-// 		fmt.Println("Json parse error", err)
-// 		os.Exit(1)
-// 	}
-//
-// 	return swayTreeJson.Dimensions.Width, swayTreeJson.Dimensions.Height
-// }
-
 type Screen struct {
-	Name string `json:"name"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"` // False for disabled and disconnected outputs
+	Dpms   bool   `json:"dpms"`   // False while the output is powered off to save energy
+	// In logical pixels, i.e. divided by Scale
 	Rect struct {
 		Width  int `json:"width"`
 		Height int `json:"height"`
 	} `json:"rect"`
+	Scale       float64 `json:"scale"`
+	Transform   string  `json:"transform"` // normal, 90, 180, 270, flipped-90, ...
+	CurrentMode struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"current_mode"`
 }
 
-func getAllOutputs() []Screen {
-	jsonBytes := swayMsgCommand(IPC_GET_OUTPUTS, "")
+// The size of the output in physical pixels, which is what the wallpaper has to be to look
+// sharp on a scaled output. sway scales the image down to the logical size itself.
+func (screen Screen) pixelSize() (int, int) {
+	width, height := screen.CurrentMode.Width, screen.CurrentMode.Height
+	if width == 0 || height == 0 {
+		// Headless outputs have no mode
+		scale := screen.Scale
+		if scale <= 0 {
+			scale = 1
+		}
+		return int(float64(screen.Rect.Width)*scale + 0.5), int(float64(screen.Rect.Height)*scale + 0.5)
+	}
+
+	// Modes are given before rotation
+	if strings.HasSuffix(screen.Transform, "90") || strings.HasSuffix(screen.Transform, "270") {
+		swap(&width, &height)
+	}
+	return width, height
+}
+
+func getAllOutputs() ([]Screen, error) {
+	jsonBytes, err := swayMsgCommand(swayipc.IPC_GET_OUTPUTS, "")
+	if err != nil {
+		return nil, err
+	}
 
 	var swayOutputs []Screen
-	err := json.Unmarshal(jsonBytes, &swayOutputs)
+	err = json.Unmarshal(jsonBytes, &swayOutputs)
 	if err != nil {
-		fmt.Println("Json parse error", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("could not parse the outputs: %w", err)
+	}
+
+	return swayOutputs, nil
+}
+
+// Disabled outputs have no size and can't show a wallpaper. Outputs that are only powered off
+// are included so that their wallpaper is already set when they come back on.
+func getActiveOutputs() ([]Screen, error) {
+	outputs, err := getAllOutputs()
+	if err != nil {
+		return nil, err
 	}
 
-	return swayOutputs
+	result := []Screen{}
+	for _, output := range outputs {
+		if output.Active {
+			result = append(result, output)
+		}
+	}
+	return result, nil
 }
 
 func getCurrentWallpaperDirectories() []string {
@@ -217,93 +204,285 @@ func getAllWallpaperPaths(parentDir string, result *[]string) []string {
 	return *result
 }
 
-func setWallpaperForScreen(screen Screen, wallpaper string) {
-	// Assume wallpaper exists
+// Maps output name to the source image currently assigned to it
+type wallpaperState map[string]string
 
-	fmt.Printf("Using %s for %s\n", wallpaper, screen.Name)
-	// homeDir, _ := os.UserHomeDir()
-	processedWallpapersRelativeDir := ".local/processed-wallpapers"
-	wallpaperOutputPath := path.Join(processedWallpapersRelativeDir, "wallpaper-"+screen.Name+".png")
-	lockScreenWallpaperPath := path.Join(processedWallpapersRelativeDir, "lock-screen-"+screen.Name+".png")
+func wallpaperStatePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return path.Join(homeDir, ".local/processed-wallpapers", "state.json")
+}
 
-	os.Stderr.WriteString("Creating lock screen wallpaper\n")
-	file, err := os.Open(wallpaper)
+func loadWallpaperState() wallpaperState {
+	state := wallpaperState{}
+
+	stateBytes, err := os.ReadFile(wallpaperStatePath())
 	if err != nil {
-		fmt.Printf("Could not load file \"%s\" with error: %+v\n", wallpaper, err)
-		os.Exit(1)
+		if !os.IsNotExist(err) {
+			fmt.Println("Could not read wallpaper state", err)
+		}
+		return state
 	}
-	defer file.Close()
 
-	img, _ /* format_name */, err := image.Decode(file)
+	err = json.Unmarshal(stateBytes, &state)
 	if err != nil {
-		fmt.Printf("Could not decode image \"%s\" with error: %+v\n", wallpaper, err)
-		os.Exit(1)
+		// Soft error, start from an empty state
+		fmt.Println("Could not parse wallpaper state", err)
+		return wallpaperState{}
 	}
 
-	imgBounds := img.Bounds()
+	return state
+}
+
+func saveWallpaperState(state wallpaperState) {
+	stateBytes, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		fmt.Println("Could not encode wallpaper state", err)
+		return
+	}
+
+	err = os.WriteFile(wallpaperStatePath(), stateBytes, 0644)
+	if err != nil {
+		fmt.Println("Could not write wallpaper state", err)
+	}
+}
+
+// Picks a random wallpaper, avoiding the current one so that a re-run always changes the image
+func pickWallpaper(rng *rand.Rand, wallpapers []string, current string) string {
+	candidates := wallpapers
+	if len(wallpapers) > 1 && slices.Contains(wallpapers, current) {
+		candidates = make([]string, 0, len(wallpapers)-1)
+		for _, wallpaper := range wallpapers {
+			if wallpaper != current {
+				candidates = append(candidates, wallpaper)
+			}
+		}
+	}
 
-	newDesktopHeight := screen.Rect.Height
-	newDesktopWidth := (imgBounds.Dx() * screen.Rect.Height) / imgBounds.Dy()
+	return candidates[rng.Intn(len(candidates))]
+}
+
+// What fills the parts of the screen that the wallpaper doesn't cover
+type fillMode string
+
+const (
+	fillBlur     fillMode = "blur"     // A blurred, zoomed in copy of the wallpaper
+	fillColor    fillMode = "color"    // Options.FillColor
+	fillDominant fillMode = "dominant" // The average color of the wallpaper
+)
 
-	newLockScreenWidth := screen.Rect.Width
-	newLockScreenHeight := (imgBounds.Dy() * screen.Rect.Width) / imgBounds.Dx()
+type Options struct {
+	Fill      fillMode
+	FillColor color.RGBA
+	Preview   bool // Show each processed wallpaper in the terminal
+	Single    bool // Every output gets the same wallpaper
+	Seeded    bool // Picks may repeat the current wallpaper, so that a seed always gives the same picks
+}
 
-	if newLockScreenHeight < screen.Rect.Height {
-		fmt.Println("Swapping locks screen and desktop dims")
-		swap(&newDesktopHeight, &newLockScreenHeight)
-		swap(&newDesktopWidth, &newLockScreenWidth)
+func defaultOptions() Options {
+	return Options{
+		Fill:      fillBlur,
+		FillColor: color.RGBA{0, 0, 0, 0xFF},
 	}
+}
+
+func parseHexColor(hex string) (color.RGBA, error) {
+	result := color.RGBA{A: 0xFF}
 
-	screenRect := image.Rectangle{
-		Min: image.Pt(0, 0),
-		Max: image.Pt(screen.Rect.Width, screen.Rect.Height),
+	_, err := fmt.Sscanf(strings.TrimPrefix(hex, "#"), "%02x%02x%02x", &result.R, &result.G, &result.B)
+	if err != nil {
+		return result, fmt.Errorf("invalid color \"%s\", expected #RRGGBB: %w", hex, err)
 	}
 
-	// Draw lock screen image
-	lockScreenFilter := gift.New(
-		gift.GaussianBlur(5.0),
-		gift.Resize(newLockScreenWidth, newLockScreenHeight, gift.LinearResampling),
-		gift.CropToSize(screen.Rect.Width, screen.Rect.Height, gift.CenterAnchor),
-	)
+	return result, nil
+}
 
-	outputImage := image.NewRGBA(screenRect)
-	lockScreenFilter.Draw(outputImage, img)
+// Averages a downscaled copy of the image, which is close enough to the dominant color for a background
+func averageColor(img image.Image) color.RGBA {
+	const sampleSize = 16
+	downscale := gift.New(gift.Resize(sampleSize, sampleSize, gift.BoxResampling))
+	sample := image.NewRGBA(downscale.Bounds(img.Bounds()))
+	downscale.Draw(sample, img)
+
+	var r, g, b, count int
+	bounds := sample.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := sample.RGBAAt(x, y)
+			r += int(pixel.R)
+			g += int(pixel.G)
+			b += int(pixel.B)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return color.RGBA{0, 0, 0, 0xFF}
+	}
+
+	return color.RGBA{uint8(r / count), uint8(g / count), uint8(b / count), 0xFF}
+}
+
+// Outputs whose wallpaper can't be set keep their old one and the rest are still changed. Returns
+// every failure.
+func setRandomWallpapers(outputs []Screen, wallpapers []string, state wallpaperState, rng *rand.Rand, opts Options) error {
+	if len(wallpapers) == 0 {
+		return nil
+	}
+
+	commands := swayCommands{}
+	defer commands.run()
+
+	if opts.Single && len(outputs) > 0 {
+		// The first output's wallpaper stands in for all of them
+		current := state[outputs[0].Name]
+		if opts.Seeded {
+			current = ""
+		}
+
+		wallpaper := pickWallpaper(rng, wallpapers, current)
+		img, err := loadWallpaper(wallpaper)
+		if err != nil {
+			return err
+		}
+
+		errs := []error{}
+		for _, output := range outputs {
+			err := setWallpaperImageForScreen(&commands, output, wallpaper, img, opts)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			state[output.Name] = wallpaper
+		}
+		return errors.Join(errs...)
+	}
+
+	errs := []error{}
+	for _, output := range outputs {
+		current := state[output.Name]
+		if opts.Seeded {
+			current = ""
+		}
+
+		wallpaper := pickWallpaper(rng, wallpapers, current)
+		err := setWallpaperForScreen(&commands, output, wallpaper, opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		state[output.Name] = wallpaper
+	}
+	return errors.Join(errs...)
+}
 
-	lockScreenFile, err := os.Create(lockScreenWallpaperPath)
+// Decodes a wallpaper the right way up, for when it goes on several outputs
+func loadWallpaper(wallpaper string) (image.Image, error) {
+	file, err := openWallpaper(wallpaper)
 	if err != nil {
-		fmt.Printf("Could not create image at \"%s\". Error: %+v\n", lockScreenWallpaperPath, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("could not load \"%s\": %w", wallpaper, err)
+	}
+	defer file.Close()
+
+	img, err := decodeWallpaper(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode image \"%s\": %w", wallpaper, err)
+	}
+
+	return img, nil
+}
+
+func setWallpaperForScreen(commands *swayCommands, screen Screen, wallpaper string, opts Options) error {
+	file, err := openWallpaper(wallpaper)
+	if err != nil {
+		return fmt.Errorf("could not load \"%s\": %w", wallpaper, err)
 	}
-	defer lockScreenFile.Close()
+	defer file.Close()
 
-	png.Encode(lockScreenFile, outputImage)
+	screenWidth, screenHeight := screen.pixelSize()
+	desktop, lockscreen, err := ProcessWallpaper(file, screenWidth, screenHeight, opts)
+	if err != nil {
+		return fmt.Errorf("could not decode image \"%s\": %w", wallpaper, err)
+	}
 
-	// Draw Desktop Image
-	os.Stderr.WriteString("Creating desktop wallpaper\n")
-	desktopFilter := gift.New(gift.Resize(newDesktopWidth, newDesktopHeight, gift.LinearResampling))
+	return writeWallpaperForScreen(commands, screen, wallpaper, desktop, lockscreen, opts)
+}
 
-	// desktopOutputImage := image.NewRGBA(screenRect)
-	// lockScreenFilter.Draw(desktopOutputImage, img)
+func writePNG(imagePath string, img image.Image) error {
+	file, err := os.Create(imagePath)
+	if err != nil {
+		return fmt.Errorf("could not create image at \"%s\": %w", imagePath, err)
+	}
 
-	centeredOrigin := image.Pt(screen.Rect.Width/2-newDesktopWidth/2, screen.Rect.Height/2-newDesktopHeight/2)
-	desktopFilter.DrawAt(outputImage, img, centeredOrigin, gift.OverOperator)
+	err = png.Encode(file, img)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write image at \"%s\": %w", imagePath, err)
+	}
+	return nil
+}
 
-	fmt.Printf("         Image dims: (%d, %d)\n", imgBounds.Dx(), imgBounds.Dy())
-	fmt.Printf("        Screen dims: (%d, %d)\n", screen.Rect.Width, screen.Rect.Height)
-	fmt.Printf("   Lock screen dims: (%d, %d)\n", newLockScreenWidth, newLockScreenHeight)
-	fmt.Printf("       Desktop dims: (%d, %d)\n", newDesktopWidth, newDesktopHeight)
-	fmt.Printf("Output image bounds: %+v\n", outputImage.Bounds())
+// Sway runs every command of a message separated by ";", so the outputs of one run all change at
+// once and the socket is only opened once, after every wallpaper has been processed
+type swayCommands []string
 
-	fmt.Printf("  Lock screen bounds after filter: %+v\n", lockScreenFilter.Bounds(imgBounds))
-	fmt.Printf("Desktop image bounds after filter: %+v\n", desktopFilter.Bounds(imgBounds))
+// A pointer so that a deferred run sends the commands that were added after the defer
+func (commands *swayCommands) run() {
+	if len(*commands) == 0 {
+		return
+	}
 
-	desktopFile, err := os.Create(wallpaperOutputPath)
+	response, err := swayMsgCommand(swayipc.IPC_COMMAND, strings.Join(*commands, "; "))
 	if err != nil {
-		fmt.Printf("Could not create image at \"%s\". Error: %+v\n", wallpaperOutputPath, err)
-		os.Exit(1)
+		fmt.Println("Could not run", *commands, err)
+		return
+	}
+
+	var results []struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(response, &results); err != nil {
+		fmt.Println("Could not read the reply to", *commands, err)
+		return
+	}
+
+	for i, result := range results {
+		if !result.Success && i < len(*commands) {
+			fmt.Println("Sway could not run", (*commands)[i], result.Error)
+		}
+	}
+}
+
+// For when the same wallpaper goes on several outputs, so that it's only decoded once
+func setWallpaperImageForScreen(commands *swayCommands, screen Screen, wallpaper string, img image.Image, opts Options) error {
+	screenWidth, screenHeight := screen.pixelSize()
+	desktop, lockscreen := renderWallpaper(img, screenWidth, screenHeight, opts)
+	return writeWallpaperForScreen(commands, screen, wallpaper, desktop, lockscreen, opts)
+}
+
+// Writes the processed images and adds the command that puts the desktop one on the output. The
+// output is only changed once the commands are run.
+func writeWallpaperForScreen(commands *swayCommands, screen Screen, wallpaper string, desktop, lockscreen image.Image, opts Options) error {
+	fmt.Printf("Using %s for %s\n", wallpaper, screen.Name)
+	if opts.Preview {
+		printPreview(desktop, wallpaper)
+	}
+
+	// homeDir, _ := os.UserHomeDir()
+	processedWallpapersRelativeDir := ".local/processed-wallpapers"
+	wallpaperOutputPath := path.Join(processedWallpapersRelativeDir, "wallpaper-"+screen.Name+".png")
+	lockScreenWallpaperPath := path.Join(processedWallpapersRelativeDir, "lock-screen-"+screen.Name+".png")
+
+	err := writePNG(lockScreenWallpaperPath, lockscreen)
+	if err != nil {
+		return err
+	}
+	err = writePNG(wallpaperOutputPath, desktop)
+	if err != nil {
+		return err
 	}
-	defer desktopFile.Close()
-	png.Encode(desktopFile, outputImage)
 
 	// TODO: Drop shadow
 	// https://en.wikipedia.org/wiki/Drop_shadow
@@ -314,12 +493,51 @@ func setWallpaperForScreen(screen Screen, wallpaper string) {
 	// 	}),
 	// )
 
-	fmt.Println("Updating output to", screen, wallpaperOutputPath)
-	swayMsgCommand(IPC_COMMAND, fmt.Sprintf("output \"%s\" bg \"%s\" fit", screen.Name, wallpaperOutputPath))
+	verbose.Println("Updating output to", screen, wallpaperOutputPath)
+	*commands = append(*commands, fmt.Sprintf("output \"%s\" bg \"%s\" fill", screen.Name, wallpaperOutputPath))
+	return nil
+}
+
+// Checks the options and the arguments, an output name and a wallpaper, against the outputs and
+// wallpapers that exist. Returns the output to change, or nil without arguments, when every
+// output gets a random wallpaper.
+func validateArguments(opts Options, args []string, outputs []Screen, wallpapers []string) (*Screen, string, error) {
+	if opts.Fill != fillBlur && opts.Fill != fillColor && opts.Fill != fillDominant {
+		return nil, "", fmt.Errorf("unknown fill mode \"%s\", options are: blur, color, dominant", opts.Fill)
+	}
+
+	if len(args) == 0 {
+		return nil, "", nil
+	}
+
+	outputName := args[0]
+	wallpaper := ""
+	if len(args) > 1 {
+		wallpaper = args[1]
+	}
+
+	outputIndex := slices.IndexFunc(outputs, func(screen Screen) bool { return screen.Name == outputName })
+	if outputIndex < 0 {
+		outputNames := []string{}
+		for _, output := range outputs {
+			outputNames = append(outputNames, output.Name)
+		}
+		return nil, "", fmt.Errorf("%s is not a valid output, options are: %s", outputName, strings.Join(outputNames, ", "))
+	}
+
+	if !isStreamedWallpaper(wallpaper) && !slices.Contains(wallpapers, wallpaper) {
+		return nil, "", fmt.Errorf("wallpaper \"%s\" does not exist in path", wallpaper)
+	}
+
+	return &outputs[outputIndex], wallpaper, nil
 }
 
 func main() {
-	outputs := getAllOutputs()
+	outputs, err := getActiveOutputs()
+	if err != nil {
+		fmt.Println("Could not get the outputs from sway", err)
+		os.Exit(1)
+	}
 	wallpaperDirs := getCurrentWallpaperDirectories()
 
 	wallpapers := []string{}
@@ -331,40 +549,127 @@ func main() {
 	processedWallpapersDir := path.Join(homeDir, ".local/processed-wallpapers")
 	ensureDirExists(processedWallpapersDir)
 
-	if len(os.Args) <= 1 {
-		if len(wallpapers) > 0 {
-			source := rand.NewSource(time.Now().UnixNano())
-			rng := rand.New(source)
+	opts := defaultOptions()
+	fill := flag.String("fill", string(opts.Fill), "What fills the screen around the wallpaper: blur, color or dominant")
+	fillColorHex := flag.String("fill-color", "#000000", "Fill color for -fill color")
+	regenerate := flag.Bool("regenerate", false, "Reprocess and reapply the current wallpaper of every output")
+	daemon := flag.Bool("daemon", false, "Keep running and change wallpapers every -interval or on SIGUSR1")
+	interval := flag.Duration("interval", 30*time.Minute, "How often the daemon changes wallpapers")
+	preview := flag.Bool("preview", false, "Show a small preview of each chosen wallpaper if the terminal can display images")
+	single := flag.Bool("single", false, "Put the same wallpaper on every output")
+	seed := flag.Int64("seed", 0, "Seed for picking wallpapers, e.g. $(date +%Y%m%d) for a wallpaper of the day. The same seed and wallpapers always give the same picks. Random if not given.")
+	verboseFlag := flag.Bool("v", false, "Print details of how each wallpaper is processed")
+	flag.BoolVar(verboseFlag, "verbose", false, "Same as -v")
+	validate := flag.Bool("validate", false, "Report wallpapers that can't be decoded or are too small for every output, without changing any")
+	screenshotLock := flag.Bool("screenshot-lock", false, "Make each output's lock screen image a blurred screenshot of it, e.g. just before locking. Needs grim.")
+	flag.Parse()
+
+	if *verboseFlag {
+		verbose.SetOutput(os.Stderr)
+	}
+
+	if *validate {
+		if !validateWallpapers(wallpapers, outputs) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *screenshotLock {
+		setScreenshotLockScreens(outputs)
+		return
+	}
+
+	opts.Fill = fillMode(*fill)
+	fillColorValue, err := parseHexColor(*fillColorHex)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	opts.FillColor = fillColorValue
+	opts.Preview = *preview
+	opts.Single = *single
+
+	args := flag.Args()
+	target, wallpaper, err := validateArguments(opts, args, outputs, wallpapers)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	state := loadWallpaperState()
 
-			for _, output := range outputs {
-				setWallpaperForScreen(output, wallpapers[rng.Intn(len(wallpapers))])
+	if *regenerate {
+		commands := swayCommands{}
+		failed := false
+		for _, output := range outputs {
+			wallpaper, exists := state[output.Name]
+			if !exists {
+				fmt.Println("No wallpaper recorded for", output.Name)
+				continue
+			}
+
+			if _, err := os.Stat(wallpaper); err != nil {
+				fmt.Println("Could not find", wallpaper, "for", output.Name, err)
+				continue
+			}
+
+			if err := setWallpaperForScreen(&commands, output, wallpaper, opts); err != nil {
+				fmt.Println("Could not set the wallpaper for", output.Name, err)
+				failed = true
 			}
 		}
-	} else {
-		outputName := os.Args[1]
-		wallpaper := ""
-		if len(os.Args) > 2 {
-			wallpaper = os.Args[2]
+		commands.run()
+
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	seedValue := time.Now().UnixNano()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedValue = *seed
+			opts.Seeded = true
 		}
+	})
+	source := rand.NewSource(seedValue)
+	rng := rand.New(source)
 
-		// outputNames := []string{}
-		// for _, Output := range swayOutputs {
-		// 	outputNames = append(outputNames, Output.Name)
-		// }
+	if *daemon {
+		runDaemon(*interval, wallpapers, state, rng, opts)
+		return
+	}
 
-		outputIndex := slices.IndexFunc(outputs, func(screen Screen) bool { return screen.Name == outputName })
-		if outputIndex >= 0 {
-			fmt.Println(outputName, "is not a valid output. Options are:", outputs)
+	if target == nil {
+		err := setRandomWallpapers(outputs, wallpapers, state, rng, opts)
+		if err != nil {
+			// The outputs that did change are still recorded
+			fmt.Println(err)
+			saveWallpaperState(state)
 			os.Exit(1)
 		}
+	} else {
+		output := *target
 
-		output := outputs[outputIndex]
+		commands := swayCommands{}
+		if isStreamedWallpaper(wallpaper) {
+			if err := setWallpaperForScreen(&commands, output, wallpaper, opts); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			commands.run()
+			return
+		}
 
-		if slices.Contains(wallpapers, wallpaper) {
-			fmt.Println("Wallpaper", wallpaper, "does not exist in path")
+		if err := setWallpaperForScreen(&commands, output, wallpaper, opts); err != nil {
+			fmt.Println(err)
 			os.Exit(1)
 		}
-
-		setWallpaperForScreen(output, wallpaper)
+		commands.run()
+		state[output.Name] = wallpaper
 	}
+
+	saveWallpaperState(state)
 }
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"swayipc"
+)
+
+// Answers every message with reply and keeps count of the connections that are still open.
+// swayipc caches the socket path for the whole process, so every test shares one server.
+type fakeSway struct {
+	lock  sync.Mutex
+	reply func(msgType uint32, payload []byte) []byte
+	open  int
+}
+
+var sharedFakeSway struct {
+	once   sync.Once
+	server *fakeSway
+	err    error
+}
+
+func startFakeSway(t *testing.T, reply func(msgType uint32, payload []byte) []byte) *fakeSway {
+	t.Helper()
+
+	sharedFakeSway.once.Do(func() {
+		directory, err := os.MkdirTemp("", "set-wallpaper-test")
+		if err != nil {
+			sharedFakeSway.err = err
+			return
+		}
+
+		socketPath := filepath.Join(directory, "sway.sock")
+		listener, err := net.Listen("unix", socketPath)
+		if err != nil {
+			sharedFakeSway.err = err
+			return
+		}
+		os.Setenv("SWAYSOCK", socketPath)
+
+		server := &fakeSway{}
+		go server.serve(listener)
+		sharedFakeSway.server = server
+	})
+	if sharedFakeSway.err != nil {
+		t.Fatal("Could not start a fake sway", sharedFakeSway.err)
+	}
+
+	server := sharedFakeSway.server
+	server.lock.Lock()
+	server.reply = reply
+	server.lock.Unlock()
+	return server
+}
+
+func (server *fakeSway) serve(listener net.Listener) {
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		server.lock.Lock()
+		server.open++
+		server.lock.Unlock()
+
+		go server.handle(connection)
+	}
+}
+
+// Only returns once the client closes its end
+func (server *fakeSway) handle(connection net.Conn) {
+	defer func() {
+		connection.Close()
+		server.lock.Lock()
+		server.open--
+		server.lock.Unlock()
+	}()
+
+	for {
+		msgType, payload, err := swayipc.ReadMessage(connection)
+		if err != nil {
+			return
+		}
+
+		server.lock.Lock()
+		reply := server.reply(msgType, payload)
+		server.lock.Unlock()
+
+		if err := swayipc.WriteMessage(connection, msgType, reply); err != nil {
+			return
+		}
+	}
+}
+
+// Waits for the clients to close every connection, since the server only notices a moment later
+func (server *fakeSway) waitForClosedConnections(timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		server.lock.Lock()
+		open := server.open
+		server.lock.Unlock()
+
+		if open == 0 || time.Now().After(deadline) {
+			return open
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func openFileDescriptors(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("Can't count file descriptors", err)
+	}
+	return len(entries)
+}
+
+func TestSwayMsgCommandClosesConnections(t *testing.T) {
+	server := startFakeSway(t, func(msgType uint32, payload []byte) []byte {
+		return []byte(`[{"success":true}]`)
+	})
+
+	// The first message also looks up the socket, which may open files of its own
+	reply, err := swayMsgCommand(swayipc.IPC_COMMAND, "nop")
+	if err != nil || string(reply) != `[{"success":true}]` {
+		t.Fatalf("Got reply %q, %v", reply, err)
+	}
+	server.waitForClosedConnections(time.Second)
+
+	before := openFileDescriptors(t)
+	for i := 0; i < 200; i++ {
+		swayMsgCommand(swayipc.IPC_COMMAND, "nop")
+	}
+
+	if open := server.waitForClosedConnections(2 * time.Second); open != 0 {
+		t.Errorf("%d connections were left open", open)
+	}
+	if after := openFileDescriptors(t); after > before {
+		t.Errorf("Open file descriptors went from %d to %d", before, after)
+	}
+}
+
+// The bg commands are only sent once every output's wallpaper has been processed
+func TestSetRandomWallpapersSendsCommands(t *testing.T) {
+	var lock sync.Mutex
+	sent := []string{}
+	startFakeSway(t, func(msgType uint32, payload []byte) []byte {
+		lock.Lock()
+		defer lock.Unlock()
+		sent = append(sent, string(payload))
+		return []byte(`[{"success":true},{"success":true}]`)
+	})
+
+	wallpaper := filepath.Join(t.TempDir(), "wallpaper.png")
+	if err := os.WriteFile(wallpaper, testWallpaperPNG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	chdirTemp(t)
+
+	outputs := []Screen{testScreen("DP-1", 48, 48), testScreen("DP-2", 96, 32)}
+	state := wallpaperState{}
+	setRandomWallpapers(outputs, []string{wallpaper}, state, rand.New(rand.NewSource(1)), defaultOptions())
+
+	lock.Lock()
+	defer lock.Unlock()
+	want := `output "DP-1" bg ".local/processed-wallpapers/wallpaper-DP-1.png" fill; output "DP-2" bg ".local/processed-wallpapers/wallpaper-DP-2.png" fill`
+	if len(sent) != 1 || sent[0] != want {
+		t.Errorf("Sent %q, want %q", sent, want)
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	outputs := []Screen{testScreen("DP-1", 2560, 1440), testScreen("HDMI-A-1", 1920, 1080)}
+	wallpapers := []string{"/home/user/wallpapers/a.png", "/home/user/wallpapers/b.jpg"}
+	withFill := func(fill fillMode) Options {
+		opts := defaultOptions()
+		opts.Fill = fill
+		return opts
+	}
+
+	tests := []struct {
+		name          string
+		opts          Options
+		args          []string
+		outputs       []Screen
+		wantOutput    string // Empty for every output
+		wantWallpaper string
+		wantError     string // Empty for no error
+	}{
+		{name: "no arguments", opts: defaultOptions(), outputs: outputs},
+		{name: "no arguments or outputs", opts: defaultOptions()},
+		// Both of these used to be rejected by inverted checks
+		{
+			name:          "known output and wallpaper",
+			opts:          defaultOptions(),
+			args:          []string{"HDMI-A-1", "/home/user/wallpapers/b.jpg"},
+			outputs:       outputs,
+			wantOutput:    "HDMI-A-1",
+			wantWallpaper: "/home/user/wallpapers/b.jpg",
+		},
+		{
+			name:      "unknown output",
+			opts:      defaultOptions(),
+			args:      []string{"eDP-1", "/home/user/wallpapers/a.png"},
+			outputs:   outputs,
+			wantError: "eDP-1 is not a valid output, options are: DP-1, HDMI-A-1",
+		},
+		{
+			name:      "no outputs",
+			opts:      defaultOptions(),
+			args:      []string{"DP-1", "/home/user/wallpapers/a.png"},
+			wantError: "DP-1 is not a valid output",
+		},
+		{
+			name:      "wallpaper outside the wallpaper directories",
+			opts:      defaultOptions(),
+			args:      []string{"DP-1", "/tmp/c.png"},
+			outputs:   outputs,
+			wantError: `wallpaper "/tmp/c.png" does not exist in path`,
+		},
+		{
+			name:      "output without a wallpaper",
+			opts:      defaultOptions(),
+			args:      []string{"DP-1"},
+			outputs:   outputs,
+			wantError: `wallpaper "" does not exist in path`,
+		},
+		{
+			name:          "stdin",
+			opts:          defaultOptions(),
+			args:          []string{"DP-1", "-"},
+			outputs:       outputs,
+			wantOutput:    "DP-1",
+			wantWallpaper: "-",
+		},
+		{
+			name:          "URL",
+			opts:          defaultOptions(),
+			args:          []string{"DP-1", "https://example.com/wallpaper.png"},
+			outputs:       outputs,
+			wantOutput:    "DP-1",
+			wantWallpaper: "https://example.com/wallpaper.png",
+		},
+		{
+			name:      "streamed wallpaper for an unknown output",
+			opts:      defaultOptions(),
+			args:      []string{"eDP-1", "-"},
+			outputs:   outputs,
+			wantError: "eDP-1 is not a valid output",
+		},
+		{
+			name:    "color fill",
+			opts:    withFill(fillColor),
+			outputs: outputs,
+		},
+		{
+			name:      "unknown fill",
+			opts:      withFill("gradient"),
+			args:      []string{"DP-1", "/home/user/wallpapers/a.png"},
+			outputs:   outputs,
+			wantError: `unknown fill mode "gradient"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, wallpaper, err := validateArguments(test.opts, test.args, test.outputs, wallpapers)
+			if test.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantError) {
+					t.Errorf("Got error %v, want %s", err, test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outputName := ""
+			if output != nil {
+				outputName = output.Name
+			}
+			if outputName != test.wantOutput || wallpaper != test.wantWallpaper {
+				t.Errorf("Got output %q and wallpaper %q, want %q and %q", outputName, wallpaper, test.wantOutput, test.wantWallpaper)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strings"
+
+	"github.com/disintegration/gift"
+)
+
+type previewProtocol int
+
+const (
+	previewNone      previewProtocol = iota // Only the path is printed
+	previewKitty                            // Kitty graphics protocol, also supported by WezTerm and Ghostty
+	previewITerm                            // iTerm2 inline images
+	previewTrueColor                        // Colored half blocks, one pixel per half character
+)
+
+// Terminals can't reliably be asked what they support without reading the reply from the
+// terminal, so this goes by the environment variables that they set
+func detectPreviewProtocol() previewProtocol {
+	stat, err := os.Stdout.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return previewNone
+	}
+
+	term := os.Getenv("TERM")
+	termProgram := os.Getenv("TERM_PROGRAM")
+	colorTerm := os.Getenv("COLORTERM")
+
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || termProgram == "WezTerm" || termProgram == "ghostty":
+		return previewKitty
+	case termProgram == "iTerm.app":
+		return previewITerm
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		return previewTrueColor
+	}
+	return previewNone
+}
+
+const (
+	previewWidth        = 480 // Pixels for the image protocols
+	previewColumns      = 60  // Characters for half blocks
+	kittyChunkSize      = 4096
+	kittyFirstChunk     = "\x1b_Gf=100,a=T,m=%d;%s\x1b\\"
+	kittyFollowingChunk = "\x1b_Gm=%d;%s\x1b\\"
+)
+
+func printPreview(img image.Image, wallpaper string) {
+	protocol := detectPreviewProtocol()
+	if protocol == previewNone {
+		fmt.Println("Preview not supported by this terminal:", wallpaper)
+		return
+	}
+
+	width := previewWidth
+	if protocol == previewTrueColor {
+		width = previewColumns
+	}
+
+	filter := gift.New(gift.Resize(width, 0, gift.LinearResampling))
+	small := image.NewRGBA(filter.Bounds(img.Bounds()))
+	filter.Draw(small, img)
+
+	switch protocol {
+	case previewKitty, previewITerm:
+		var encoded bytes.Buffer
+		err := png.Encode(&encoded, small)
+		if err != nil {
+			// Soft error, the wallpaper is still set
+			fmt.Println("Could not encode preview", err)
+			return
+		}
+		data := base64.StdEncoding.EncodeToString(encoded.Bytes())
+
+		if protocol == previewITerm {
+			fmt.Printf("\x1b]1337;File=inline=1;size=%d:%s\a\n", encoded.Len(), data)
+			return
+		}
+
+		for start := 0; start < len(data); start += kittyChunkSize {
+			end := start + kittyChunkSize
+			more := 1
+			if end >= len(data) {
+				end = len(data)
+				more = 0
+			}
+
+			format := kittyFollowingChunk
+			if start == 0 {
+				format = kittyFirstChunk
+			}
+			fmt.Printf(format, more, data[start:end])
+		}
+		fmt.Println()
+
+	case previewTrueColor:
+		printHalfBlocks(small)
+	}
+}
+
+// Each character is two pixels tall, the top one in the foreground and the bottom one behind it
+func printHalfBlocks(img *image.RGBA) {
+	bounds := img.Bounds()
+	var output strings.Builder
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top := img.RGBAAt(x, y)
+			bottom := top
+			if y+1 < bounds.Max.Y {
+				bottom = img.RGBAAt(x, y+1)
+			}
+			fmt.Fprintf(&output, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		output.WriteString("\x1b[0m\n")
+	}
+
+	fmt.Print(output.String())
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"io"
+
+	"github.com/disintegration/gift"
+)
+
+// Decodes the wallpaper in src and renders it for a screen of the given size in pixels. The
+// desktop image is the whole wallpaper centered on a fill, the lock screen image is a blurred
+// copy cropped to fill the screen. Nothing is read or written apart from src.
+func ProcessWallpaper(src io.Reader, screenW, screenH int, opts Options) (desktop, lockscreen image.Image, err error) {
+	img, err := decodeWallpaper(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desktop, lockscreen = renderWallpaper(img, screenW, screenH, opts)
+	return desktop, lockscreen, nil
+}
+
+// Decodes an image the right way up
+func decodeWallpaper(src io.Reader) (image.Image, error) {
+	// The EXIF orientation is read in a second pass
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	img, formatName, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Photos are often stored sideways with an EXIF tag saying how to display them
+	if formatName == "jpeg" {
+		img = applyOrientation(img, readJPEGOrientation(bytes.NewReader(data)))
+	}
+
+	return img, nil
+}
+
+// For when the wallpaper has already been decoded, e.g. to put it on several outputs
+func renderWallpaper(img image.Image, screenWidth, screenHeight int, opts Options) (desktop, lockscreen *image.RGBA) {
+	imgBounds := img.Bounds()
+
+	newDesktopHeight := screenHeight
+	newDesktopWidth := (imgBounds.Dx() * screenHeight) / imgBounds.Dy()
+
+	newLockScreenWidth := screenWidth
+	newLockScreenHeight := (imgBounds.Dy() * screenWidth) / imgBounds.Dx()
+
+	if newLockScreenHeight < screenHeight {
+		verbose.Println("Swapping locks screen and desktop dims")
+		swap(&newDesktopHeight, &newLockScreenHeight)
+		swap(&newDesktopWidth, &newLockScreenWidth)
+	}
+
+	screenRect := image.Rectangle{
+		Min: image.Pt(0, 0),
+		Max: image.Pt(screenWidth, screenHeight),
+	}
+
+	// Draw lock screen image
+	verbose.Println("Creating lock screen wallpaper")
+	lockscreen = blurLockScreen(img, screenWidth, screenHeight, newLockScreenWidth, newLockScreenHeight)
+
+	// Draw Desktop Image. The blur fill is the lock screen image.
+	verbose.Println("Creating desktop wallpaper")
+	desktop = image.NewRGBA(screenRect)
+	switch opts.Fill {
+	case fillColor:
+		draw.Draw(desktop, screenRect, image.NewUniform(opts.FillColor), image.Point{}, draw.Src)
+	case fillDominant:
+		draw.Draw(desktop, screenRect, image.NewUniform(averageColor(img)), image.Point{}, draw.Src)
+	default:
+		draw.Draw(desktop, screenRect, lockscreen, image.Point{}, draw.Src)
+	}
+
+	desktopFilter := gift.New(gift.Resize(newDesktopWidth, newDesktopHeight, gift.LinearResampling))
+
+	centeredOrigin := image.Pt(screenWidth/2-newDesktopWidth/2, screenHeight/2-newDesktopHeight/2)
+	desktopFilter.DrawAt(desktop, img, centeredOrigin, gift.OverOperator)
+
+	verbose.Printf("         Image dims: (%d, %d)\n", imgBounds.Dx(), imgBounds.Dy())
+	verbose.Printf("        Screen dims: (%d, %d)\n", screenWidth, screenHeight)
+	verbose.Printf("   Lock screen dims: (%d, %d)\n", newLockScreenWidth, newLockScreenHeight)
+	verbose.Printf("       Desktop dims: (%d, %d)\n", newDesktopWidth, newDesktopHeight)
+	verbose.Printf("Output image bounds: %+v\n", desktop.Bounds())
+
+	verbose.Printf("Desktop image bounds after filter: %+v\n", desktopFilter.Bounds(imgBounds))
+
+	return desktop, lockscreen
+}
+
+// Blurs img, scales it to width by height and crops it to the screen around the center
+func blurLockScreen(img image.Image, screenWidth, screenHeight, width, height int) *image.RGBA {
+	lockScreenFilter := gift.New(
+		gift.GaussianBlur(5.0),
+		gift.Resize(width, height, gift.LinearResampling),
+		gift.CropToSize(screenWidth, screenHeight, gift.CenterAnchor),
+	)
+
+	lockscreen := image.NewRGBA(image.Rect(0, 0, screenWidth, screenHeight))
+	lockScreenFilter.Draw(lockscreen, img)
+
+	verbose.Printf("  Lock screen bounds after filter: %+v\n", lockScreenFilter.Bounds(img.Bounds()))
+	return lockscreen
+}
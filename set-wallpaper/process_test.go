@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden images in testdata")
+
+// The corners of the upright wallpaper with a gradient across it, so that scaling and blurring
+// show up in the output
+func testWallpaperPNG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for which, fill := range uprightColors {
+		draw.Draw(img, quadrant(img.Bounds(), which), image.NewUniform(fill), image.Point{}, draw.Src)
+	}
+	for x := 0; x < 64; x++ {
+		img.SetRGBA(x, 15, color.RGBA{uint8(x * 4), 128, uint8(255 - x*4), 255})
+		img.SetRGBA(x, 16, color.RGBA{uint8(x * 4), 128, uint8(255 - x*4), 255})
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+	return encoded.Bytes()
+}
+
+// Allows a little rounding difference, since floating point results can differ between machines
+func assertGoldenImage(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	goldenPath := filepath.Join("testdata", name)
+
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writePNG(goldenPath, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(goldenPath)
+	if err != nil {
+		t.Fatal("Run with -update to create it", err)
+	}
+	defer file.Close()
+	golden, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds() != golden.Bounds() {
+		t.Fatalf("%s: bounds are %v, want %v", name, img.Bounds(), golden.Bounds())
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			got := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			want := color.RGBAModel.Convert(golden.At(x, y)).(color.RGBA)
+			near := func(a, b uint8) bool { return int(a)-int(b) <= 1 && int(b)-int(a) <= 1 }
+			if !near(got.R, want.R) || !near(got.G, want.G) || !near(got.B, want.B) || !near(got.A, want.A) {
+				t.Fatalf("%s: pixel (%d, %d) is %v, want %v", name, x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestProcessWallpaperGolden(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		fill          fillMode
+	}{
+		// Taller than the wallpaper, so the fill shows above and below
+		{"blur", 48, 48, fillBlur},
+		{"color", 48, 48, fillColor},
+		{"dominant", 48, 48, fillDominant},
+		// Wider than the wallpaper, so the fill shows at the sides
+		{"wide", 96, 32, fillBlur},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.Fill = test.fill
+			opts.FillColor = color.RGBA{0x28, 0x2A, 0x36, 0xFF}
+
+			desktop, lockscreen, err := ProcessWallpaper(bytes.NewReader(testWallpaperPNG(t)), test.width, test.height, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGoldenImage(t, "desktop-"+test.name+".png", desktop)
+			// The fill only changes the desktop
+			assertGoldenImage(t, fmt.Sprintf("lock-screen-%dx%d.png", test.width, test.height), lockscreen)
+		})
+	}
+}
+
+func TestProcessWallpaperErrors(t *testing.T) {
+	_, _, err := ProcessWallpaper(strings.NewReader("not an image"), 48, 48, defaultOptions())
+	if err == nil {
+		t.Error("Expected an error for something that isn't an image")
+	}
+}
+
+// The processed images are written relative to the working directory
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory := t.TempDir()
+	if err := os.Chdir(directory); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	if err := os.MkdirAll(filepath.Join(directory, ".local/processed-wallpapers"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return directory
+}
+
+func testScreen(name string, width, height int) Screen {
+	screen := Screen{Name: name, Active: true, Scale: 1}
+	screen.CurrentMode.Width = width
+	screen.CurrentMode.Height = height
+	return screen
+}
+
+func TestSetWallpaperForScreen(t *testing.T) {
+	wallpaper := filepath.Join(t.TempDir(), "wallpaper.png")
+	if err := os.WriteFile(wallpaper, testWallpaperPNG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	directory := chdirTemp(t)
+
+	commands := swayCommands{}
+	if err := setWallpaperForScreen(&commands, testScreen("DP-1", 48, 48), wallpaper, defaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := swayCommands{`output "DP-1" bg ".local/processed-wallpapers/wallpaper-DP-1.png" fill`}
+	if len(commands) != 1 || commands[0] != want[0] {
+		t.Errorf("Commands are %q, want %q", commands, want)
+	}
+
+	for _, name := range []string{"wallpaper-DP-1.png", "lock-screen-DP-1.png"} {
+		file, err := os.Open(filepath.Join(directory, ".local/processed-wallpapers", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := png.DecodeConfig(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if config.Width != 48 || config.Height != 48 {
+			t.Errorf("%s is %dx%d, want 48x48", name, config.Width, config.Height)
+		}
+	}
+}
+
+// Nothing is written and the output is left alone, instead of exiting
+func TestSetWallpaperForScreenErrors(t *testing.T) {
+	broken := filepath.Join(t.TempDir(), "broken.png")
+	if err := os.WriteFile(broken, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	directory := chdirTemp(t)
+
+	for _, wallpaper := range []string{filepath.Join(directory, "missing.png"), broken} {
+		commands := swayCommands{}
+		err := setWallpaperForScreen(&commands, testScreen("DP-1", 48, 48), wallpaper, defaultOptions())
+		if err == nil || !strings.Contains(err.Error(), wallpaper) {
+			t.Errorf("%s: got error %v", wallpaper, err)
+		}
+		if len(commands) != 0 {
+			t.Errorf("%s: commands %q were added", wallpaper, commands)
+		}
+	}
+
+	// The processed wallpapers directory doesn't exist
+	os.RemoveAll(filepath.Join(directory, ".local"))
+	wallpaper := filepath.Join(t.TempDir(), "wallpaper.png")
+	if err := os.WriteFile(wallpaper, testWallpaperPNG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commands := swayCommands{}
+	if err := setWallpaperForScreen(&commands, testScreen("DP-1", 48, 48), wallpaper, defaultOptions()); err == nil {
+		t.Error("Expected an error when the image can't be written")
+	}
+	if len(commands) != 0 {
+		t.Errorf("Commands %q were added", commands)
+	}
+}
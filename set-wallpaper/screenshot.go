@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"os/exec"
+	"path"
+)
+
+// Replaces each output's lock screen image with a blurred screenshot of what is on it right now,
+// for a see-through look when run just before locking. The desktop wallpapers are left alone.
+func setScreenshotLockScreens(outputs []Screen) {
+	if _, err := exec.LookPath("grim"); err != nil {
+		fmt.Println("grim is needed to take screenshots", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, output := range outputs {
+		// Each output is captured on its own since they can have different sizes and scales
+		screenshot, err := exec.Command("grim", "-o", output.Name, "-").Output()
+		if err != nil {
+			fmt.Println("Could not take a screenshot of", output.Name, err)
+			failed = true
+			continue
+		}
+
+		img, err := png.Decode(bytes.NewReader(screenshot))
+		if err != nil {
+			fmt.Println("Could not decode the screenshot of", output.Name, err)
+			failed = true
+			continue
+		}
+
+		screenWidth, screenHeight := output.pixelSize()
+		lockscreen := blurLockScreen(img, screenWidth, screenHeight, screenWidth, screenHeight)
+
+		lockScreenWallpaperPath := path.Join(".local/processed-wallpapers", "lock-screen-"+output.Name+".png")
+		verbose.Println("Writing screenshot lock screen for", output.Name, "to", lockScreenWallpaperPath)
+		if err := writePNG(lockScreenWallpaperPath, lockscreen); err != nil {
+			fmt.Println(err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
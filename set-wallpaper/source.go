@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Downloads larger than this are cut off, which makes them fail to decode
+const maxDownloadSize = 200 << 20
+
+// Wallpapers given as "-" are read from stdin and ones given as an http or https URL are
+// downloaded. Neither is kept, so they aren't recorded as an output's wallpaper.
+func isStreamedWallpaper(wallpaper string) bool {
+	return wallpaper == "-" || strings.HasPrefix(wallpaper, "http://") || strings.HasPrefix(wallpaper, "https://")
+}
+
+func openWallpaper(wallpaper string) (io.ReadCloser, error) {
+	if wallpaper == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	if isStreamedWallpaper(wallpaper) {
+		return downloadWallpaper(wallpaper)
+	}
+
+	return os.Open(wallpaper)
+}
+
+func downloadWallpaper(wallpaperURL string) (io.ReadCloser, error) {
+	parsed, err := url.Parse(wallpaperURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("no host in URL \"%s\"", wallpaperURL)
+	}
+
+	verbose.Println("Downloading", wallpaperURL)
+	client := http.Client{Timeout: 1 * time.Minute}
+	response, err := client.Get(parsed.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("server replied %s", response.Status)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(response.Body, maxDownloadSize), response.Body}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+type wallpaperProblem struct {
+	path   string
+	reason string
+}
+
+// Checks that every wallpaper can be used without changing any of them. Returns false if any
+// can't be.
+func validateWallpapers(wallpapers []string, outputs []Screen) bool {
+	problems := []wallpaperProblem{}
+	unsupported, broken, tooSmall := 0, 0, 0
+
+	for _, wallpaper := range wallpapers {
+		width, height, err := wallpaperDimensions(wallpaper)
+		if errors.Is(err, image.ErrFormat) {
+			unsupported++
+			problems = append(problems, wallpaperProblem{wallpaper, "unsupported format"})
+			continue
+		} else if err != nil {
+			broken++
+			problems = append(problems, wallpaperProblem{wallpaper, fmt.Sprintf("could not decode: %v", err)})
+			continue
+		}
+
+		// It would be blurry on every output, even if it's fine on a smaller screen elsewhere
+		if len(outputs) > 0 && !fitsAnyOutput(width, height, outputs) {
+			tooSmall++
+			problems = append(problems, wallpaperProblem{wallpaper, fmt.Sprintf("%dx%d is smaller than every output", width, height)})
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", problem.path, problem.reason)
+	}
+
+	fmt.Printf("\nChecked %d wallpapers: %d usable, %d unsupported, %d could not be decoded, %d too small\n",
+		len(wallpapers), len(wallpapers)-len(problems), unsupported, broken, tooSmall)
+
+	return len(problems) == 0
+}
+
+// Only reads the header, with width and height swapped for sideways photos
+func wallpaperDimensions(wallpaper string) (int, int, error) {
+	file, err := os.Open(wallpaper)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	config, formatName, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	width, height := config.Width, config.Height
+	if formatName == "jpeg" {
+		if _, err := file.Seek(0, io.SeekStart); err == nil && readJPEGOrientation(file) >= 5 {
+			swap(&width, &height)
+		}
+	}
+
+	return width, height, nil
+}
+
+func fitsAnyOutput(width, height int, outputs []Screen) bool {
+	for _, output := range outputs {
+		outputWidth, outputHeight := output.pixelSize()
+		if width >= outputWidth && height >= outputHeight {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const powerSupplyPath = "/sys/class/power_supply"
+
+const lowBatteryColor = "#FF5555"
+
+// Shows the charge of all internal batteries together, e.g. BAT0 and BAT1 on laptops with two
+type batteryProvider struct {
+	present  bool
+	capacity int
+	status   string // Charging, Discharging, Full, Not charging or Unknown
+
+	lowPercent   int           // Urgent and red at or below this, defaults to 10
+	pollInterval time.Duration // Defaults to 30 seconds
+
+	// A desktop notification is sent the first time the capacity drops to each of these
+	// percentages while discharging. They are re-armed once the battery charges again.
+	notifyThresholds []int
+	notified         map[int]bool
+
+	// Run once when the capacity reaches criticalLevel while discharging, e.g. systemctl suspend
+	criticalLevel   int
+	criticalCommand []string
+	criticalRan     bool
+}
+
+func readBatteryValue(battery, name string) (string, error) {
+	value, err := os.ReadFile(filepath.Join(battery, name))
+	return strings.TrimSpace(string(value)), err
+}
+
+type batteryReading struct {
+	capacity int
+	status   string
+
+	// In µWh or µAh depending on the battery. Both are 0 when the battery reports neither.
+	now  float64
+	full float64
+}
+
+func readBattery(battery string) (batteryReading, error) {
+	capacityString, err := readBatteryValue(battery, "capacity")
+	if err != nil {
+		return batteryReading{}, err
+	}
+
+	capacity, err := strconv.Atoi(capacityString)
+	if err != nil {
+		return batteryReading{}, err
+	}
+
+	status, err := readBatteryValue(battery, "status")
+	if err != nil {
+		status = "Unknown"
+	}
+
+	reading := batteryReading{
+		capacity: capacity,
+		status:   status,
+	}
+
+	for _, prefix := range []string{"energy", "charge"} {
+		now, nowErr := readBatteryValue(battery, prefix+"_now")
+		full, fullErr := readBatteryValue(battery, prefix+"_full")
+		if nowErr != nil || fullErr != nil {
+			continue
+		}
+
+		reading.now, nowErr = strconv.ParseFloat(now, 64)
+		reading.full, fullErr = strconv.ParseFloat(full, 64)
+		if nowErr == nil && fullErr == nil {
+			break
+		}
+		reading.now, reading.full = 0, 0
+	}
+
+	return reading, nil
+}
+
+// Larger batteries count for more when they all report their charge, otherwise every battery
+// counts the same. Charging wins over discharging, which wins over the first battery's status.
+func combineBatteries(readings []batteryReading) (int, string) {
+	now, full, capacities := 0.0, 0.0, 0
+	weighted := true
+	status := readings[0].status
+	for _, reading := range readings {
+		now += reading.now
+		full += reading.full
+		capacities += reading.capacity
+		weighted = weighted && reading.full > 0
+
+		if reading.status == "Charging" || (reading.status == "Discharging" && status != "Charging") {
+			status = reading.status
+		}
+	}
+
+	if weighted {
+		return int(math.Round(now / full * 100)), status
+	}
+	return capacities / len(readings), status
+}
+
+func (bat *batteryProvider) updateBattery() {
+	// Usually BAT0, sometimes BAT1 or both
+	batteries, _ := filepath.Glob(filepath.Join(powerSupplyPath, "BAT*"))
+	sort.Strings(batteries)
+
+	readings := []batteryReading{}
+	for _, battery := range batteries {
+		reading, err := readBattery(battery)
+		if err != nil {
+			logger.Println("Could not read battery", battery, err)
+			continue
+		}
+		readings = append(readings, reading)
+	}
+
+	if len(readings) == 0 {
+		bat.present = false
+		return
+	}
+
+	bat.present = true
+	bat.capacity, bat.status = combineBatteries(readings)
+}
+
+// Fires each threshold once per discharge
+func (bat *batteryProvider) checkThresholds() {
+	if bat.notified == nil {
+		bat.notified = make(map[int]bool)
+	}
+
+	if bat.status != "Discharging" {
+		if bat.status == "Charging" || bat.status == "Full" {
+			bat.notified = make(map[int]bool)
+			bat.criticalRan = false
+		}
+		return
+	}
+
+	thresholds := append([]int{}, bat.notifyThresholds...)
+	sort.Ints(thresholds)
+
+	// If several thresholds were crossed since the last check only the lowest is worth a notification
+	for _, threshold := range thresholds {
+		if bat.capacity <= threshold && !bat.notified[threshold] {
+			launchDetached("notify-send", "-u", "critical", "Battery low", fmt.Sprintf("%d%% remaining", bat.capacity))
+			for _, crossed := range thresholds {
+				if bat.capacity <= crossed {
+					bat.notified[crossed] = true
+				}
+			}
+			break
+		}
+	}
+
+	if len(bat.criticalCommand) > 0 && bat.capacity <= bat.criticalLevel && !bat.criticalRan {
+		logger.Println("Battery critical, running", bat.criticalCommand)
+		launchDetached(bat.criticalCommand[0], bat.criticalCommand[1:]...)
+		bat.criticalRan = true
+	}
+}
+
+func (bat *batteryProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if bat.lowPercent <= 0 {
+		bat.lowPercent = 10
+	}
+
+	interval := bat.pollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	for {
+		present, capacity, status := bat.present, bat.capacity, bat.status
+		bat.updateBattery()
+		if bat.present {
+			bat.checkThresholds()
+		}
+
+		if bat.present != present || bat.capacity != capacity || bat.status != status {
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// From empty to full
+var batteryGlyphs = []string{"", "", "", "", ""}
+
+func (bat *batteryProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden on machines without a battery
+	if !bat.present {
+		return block
+	}
+
+	glyph := rampGlyph(float64(bat.capacity), batteryGlyphs)
+	if bat.status == "Charging" {
+		glyph = "󰂄"
+	}
+	block.FullText = fmt.Sprintf("%s %d%%", glyph, bat.capacity)
+	block.ShortText = glyph
+
+	if bat.capacity <= bat.lowPercent && bat.status != "Charging" {
+		urgent := true
+		block.Urgent = &urgent
+		block.Color = lowBatteryColor
+	}
+
+	return block
+}
+
+func (bat *batteryProvider) name() string {
+	return ""
+}
+
+func (bat *batteryProvider) respondToClick(event clickEvent) {}
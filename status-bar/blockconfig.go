@@ -0,0 +1,453 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Which blocks the bar shows, in what order, and their options. These are read from the same
+// config.toml as the flags, see config.go, e.g.
+//
+//	[bar]
+//	left = ["media"]
+//	center = ["time"]
+//	right = ["volume", "weather", "temperature"]
+//	left-spacer-width = 300
+//	right-spacer-width = 300
+//
+//	[volume]
+//	mixer-name = "PCM"
+//	step-size = 2
+//	mode = "poll"
+//	poll-interval = "10s"
+//	dbus = true
+//
+//	[weather]
+//	url = "https://wttr.in/Oslo?format=j1"
+//	update-interval = "30m"
+//	text-only = true
+//	unit = "fahrenheit"
+//
+//	[temperature]
+//	sensor-prefix = "Tctl"
+//	poll-interval = "10s"
+//	unit = "fahrenheit"
+//
+//	[cpu]
+//	per-core = true
+//	sample-interval = "2s"
+//
+//	[memory]
+//	show-percent = false
+//	show-swap = true
+//	viewer = ["foot", "btop"]
+//
+//	[battery]
+//	notify-thresholds = [20, 10]
+//	critical-level = 3
+//	critical-command = ["systemctl", "suspend"]
+//
+//	[wifi]
+//	level-thresholds = [30, 60, 80]
+//
+//	[time]
+//	show-seconds = true
+//	underline = 2
+//	border-color = "#BD93F9"
+//	min-update-interval = "1s"
+//
+// The names of blocks are the keys of the map built in main. Without any groups the bar shows
+// defaultBlocks on the right, the others like cpu, memory or battery have to be listed. An
+// option that is left out keeps its default. Every block's section can also have the options in
+// BlockConfig.
+type Config struct {
+	Bar    BarConfig
+	Blocks map[string]BlockConfig // By block name, for every block whether it is shown or not
+
+	Volume      VolumeConfig
+	Weather     WeatherConfig
+	Temperature TemperatureConfig
+	CPU         CPUConfig
+	Memory      MemoryConfig
+	Time        TimeConfig
+	Battery     BatteryConfig
+	Wifi        WifiConfig
+
+	DBusProperty DBusPropertyConfig
+}
+
+// The groups of blocks, see layout.go for how they are kept apart and the limits of that
+type BarConfig struct {
+	Left             []string `toml:"left"`
+	Center           []string `toml:"center"`
+	Right            []string `toml:"right"`
+	LeftSpacerWidth  int      `toml:"left-spacer-width"`  // Pixels between the left and center groups, 0 for none
+	RightSpacerWidth int      `toml:"right-spacer-width"` // Pixels between the center and right groups, 0 for none
+}
+
+// What every block can set in its section
+type BlockConfig struct {
+	blockStyle
+
+	// Changes that come in faster than this are coalesced into one redraw, which keeps a block
+	// that updates too often from redrawing the whole bar in a loop. 0 for defaultMinUpdateInterval.
+	MinUpdateInterval time.Duration `toml:"min-update-interval"`
+}
+
+func (config BlockConfig) validate() error {
+	if config.MinUpdateInterval < 0 {
+		return fmt.Errorf("min-update-interval can't be negative")
+	}
+	return config.blockStyle.validate()
+}
+
+type VolumeConfig struct {
+	BlockConfig
+	MixerName string `toml:"mixer-name"` // The amixer control, only used with the amixer backend
+	StepSize  int    `toml:"step-size"`  // Percent per scroll step
+
+	Mode         string        `toml:"mode"`          // event or poll, see updateMode
+	PollInterval time.Duration `toml:"poll-interval"` // Only with mode = "poll"
+
+	// Read the volume from PulseAudio over DBus, falling back to the backend if that fails. Needs
+	// a build with -tags dbus and module-dbus-protocol loaded.
+	DBus bool `toml:"dbus"`
+}
+
+type WeatherConfig struct {
+	BlockConfig
+	URL            string        `toml:"url"` // Must answer in wttr.in's j1 format
+	UpdateInterval time.Duration `toml:"update-interval"`
+	TextOnly       bool          `toml:"text-only"` // The description instead of an icon, for fonts without weather icons
+	Unit           string        `toml:"unit"`      // celsius or fahrenheit
+}
+
+type TemperatureConfig struct {
+	BlockConfig
+	SensorPrefix string        `toml:"sensor-prefix"` // The hottest sensors reading whose label starts with this is shown
+	PollInterval time.Duration `toml:"poll-interval"` // Also how often the fan and NVMe blocks update, they share the sensors poll
+	Unit         string        `toml:"unit"`          // celsius or fahrenheit
+}
+
+type CPUConfig struct {
+	BlockConfig
+	PerCore        bool          `toml:"per-core"` // Also show the usage of each core
+	SampleInterval time.Duration `toml:"sample-interval"`
+}
+
+type MemoryConfig struct {
+	BlockConfig
+	ShowPercent *bool         `toml:"show-percent"` // Otherwise used and total in GiB. Percentages by default.
+	ShowSwap    bool          `toml:"show-swap"`
+	Interval    time.Duration `toml:"interval"`
+	Threshold   float64       `toml:"threshold"` // Percentage points the usage has to move to update the block
+	Viewer      []string      `toml:"viewer"`    // The command run on click
+}
+
+type TimeConfig struct {
+	BlockConfig
+	ShowSeconds bool `toml:"show-seconds"` // Redraws the bar every second
+}
+
+func loadBlockConfig(config *fileConfig, providers map[string]blockProvider) (Config, error) {
+	blockConfig := Config{
+		Blocks: make(map[string]BlockConfig, len(providers)),
+	}
+	if err := config.decode("bar", &blockConfig.Bar); err != nil {
+		return Config{}, err
+	}
+
+	// Blocks with options of their own
+	sections := map[string]blockSection{
+		"volume":        &blockConfig.Volume,
+		"weather":       &blockConfig.Weather,
+		"temperature":   &blockConfig.Temperature,
+		"cpu":           &blockConfig.CPU,
+		"memory":        &blockConfig.Memory,
+		"time":          &blockConfig.Time,
+		"battery":       &blockConfig.Battery,
+		"wifi":          &blockConfig.Wifi,
+		"dbus-property": &blockConfig.DBusProperty,
+	}
+
+	for name := range providers {
+		section, exists := sections[name]
+		if !exists {
+			section = &BlockConfig{}
+		}
+		if err := config.decode(name, section); err != nil {
+			return Config{}, err
+		}
+
+		settings := section.settings()
+		if err := settings.validate(); err != nil {
+			return Config{}, fmt.Errorf("[%s]: %w", name, err)
+		}
+		blockConfig.Blocks[name] = settings
+	}
+	return blockConfig, nil
+}
+
+type BatteryConfig struct {
+	BlockConfig
+	LowPercent       int           `toml:"low-percent"` // Urgent and red at or below this
+	PollInterval     time.Duration `toml:"poll-interval"`
+	NotifyThresholds []int         `toml:"notify-thresholds"` // Percentages to send a notification at, [] for none
+	CriticalLevel    int           `toml:"critical-level"`    // Runs critical-command once at or below this
+	CriticalCommand  []string      `toml:"critical-command"`
+}
+
+type WifiConfig struct {
+	BlockConfig
+	LevelThresholds []int `toml:"level-thresholds"` // Signal percentages at which another bar is added, lowest first
+}
+
+// See dbusPropertyConfig for the options. Only in builds with -tags dbus.
+type DBusPropertyConfig struct {
+	BlockConfig
+	dbusPropertyConfig
+}
+
+// The config of a block's section, which has the options of every block as well as its own
+type blockSection interface {
+	settings() BlockConfig
+}
+
+func (config BlockConfig) settings() BlockConfig {
+	return config
+}
+
+// The settings of each block in the order they are sent, with the defaults for spacers
+func (config Config) settingsInOrder(blockProviders []blockProvider, providers map[string]blockProvider) []BlockConfig {
+	byProvider := make(map[blockProvider]BlockConfig, len(providers))
+	for name, provider := range providers {
+		byProvider[provider] = config.Blocks[name]
+	}
+
+	result := make([]BlockConfig, len(blockProviders))
+	for i, provider := range blockProviders {
+		result[i] = byProvider[provider]
+	}
+	return result
+}
+
+func (config BarConfig) layout(providers map[string]blockProvider) (blockLayout, error) {
+	layout := blockLayout{
+		leftSpacerWidth:  config.LeftSpacerWidth,
+		rightSpacerWidth: config.RightSpacerWidth,
+	}
+	if layout.leftSpacerWidth < 0 || layout.rightSpacerWidth < 0 {
+		return blockLayout{}, fmt.Errorf("spacer widths can't be negative")
+	}
+
+	right := config.Right
+	if config.Left == nil && config.Center == nil && config.Right == nil {
+		right = defaultBlocks
+	}
+
+	var err error
+	seen := make(map[string]bool)
+	if layout.left, err = selectBlocks(config.Left, providers, seen); err != nil {
+		return blockLayout{}, err
+	}
+	if layout.center, err = selectBlocks(config.Center, providers, seen); err != nil {
+		return blockLayout{}, err
+	}
+	if layout.right, err = selectBlocks(right, providers, seen); err != nil {
+		return blockLayout{}, err
+	}
+	return layout, nil
+}
+
+// Returns the providers with the names, in order. A provider can only be shown once, seen has
+// the names that are already in other groups.
+func selectBlocks(names []string, providers map[string]blockProvider, seen map[string]bool) ([]blockProvider, error) {
+	selected := make([]blockProvider, 0, len(names))
+	for _, name := range names {
+		provider, exists := providers[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown block %q in config", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("block %q is in the config more than once", name)
+		}
+		seen[name] = true
+		selected = append(selected, provider)
+	}
+	return selected, nil
+}
+
+func (config VolumeConfig) apply(volume *volumeProvider) error {
+	if config.MixerName != "" {
+		volume.mixer = config.MixerName
+	}
+	if config.StepSize > 0 {
+		volume.step = config.StepSize
+	}
+	if config.DBus {
+		volume.useDBus = true
+	}
+
+	if config.Mode != "" {
+		mode, err := parseUpdateMode(config.Mode)
+		if err != nil {
+			return fmt.Errorf("[volume]: %w", err)
+		}
+		volume.mode = mode
+	}
+
+	if config.PollInterval != 0 {
+		if volume.mode != updateModePoll {
+			return fmt.Errorf(`[volume]: poll-interval is only used with mode = "poll", the event mode updates as soon as the volume changes`)
+		}
+		if config.PollInterval < time.Second {
+			return fmt.Errorf("[volume]: poll-interval must be at least 1s, polling runs the backend's command each time. " +
+				`Use mode = "event" to see changes at once.`)
+		}
+		volume.pollInterval = config.PollInterval
+	}
+	return nil
+}
+
+func (config WeatherConfig) apply(weather *weatherProvider) error {
+	if config.URL != "" {
+		weather.url = config.URL
+	}
+	if config.UpdateInterval > 0 {
+		weather.updateInterval = config.UpdateInterval
+	}
+	if config.TextOnly {
+		weather.textOnly = true
+	}
+	if config.Unit != "" {
+		unit, err := parseTemperatureUnit(config.Unit)
+		if err != nil {
+			return fmt.Errorf("[weather]: %w", err)
+		}
+		weather.unit = unit
+	}
+	return nil
+}
+
+func (config TemperatureConfig) apply(temperature *temperatureProvider) error {
+	if config.SensorPrefix != "" {
+		temperature.labelPrefix = config.SensorPrefix
+	}
+	if config.PollInterval > 0 {
+		temperature.pollInterval = config.PollInterval
+		if temperature.sensors != nil {
+			temperature.sensors.interval = config.PollInterval
+		}
+	}
+	if config.Unit != "" {
+		unit, err := parseTemperatureUnit(config.Unit)
+		if err != nil {
+			return fmt.Errorf("[temperature]: %w", err)
+		}
+		temperature.unit = unit
+	}
+	return nil
+}
+
+func (config CPUConfig) apply(cpu *cpuProvider) {
+	if config.PerCore {
+		cpu.perCore = true
+	}
+	if config.SampleInterval > 0 {
+		cpu.sampleInterval = config.SampleInterval
+	}
+}
+
+func (config MemoryConfig) apply(memory *memoryProvider) {
+	if config.ShowPercent != nil {
+		memory.showPercent = *config.ShowPercent
+	}
+	if config.ShowSwap {
+		memory.showSwap = true
+	}
+	if config.Interval > 0 {
+		memory.interval = config.Interval
+	}
+	if config.Threshold > 0 {
+		memory.threshold = config.Threshold
+	}
+	if len(config.Viewer) > 0 {
+		memory.viewer = config.Viewer
+	}
+}
+
+func (config TimeConfig) apply(timeProvider *timeMonitor) {
+	if config.ShowSeconds {
+		timeProvider.showSeconds = true
+	}
+}
+
+func (config BatteryConfig) apply(battery *batteryProvider) error {
+	isPercentage := func(value int) bool {
+		return value >= 0 && value <= 100
+	}
+
+	if !isPercentage(config.LowPercent) {
+		return fmt.Errorf("[battery]: low-percent must be between 0 and 100")
+	}
+	if config.LowPercent > 0 {
+		battery.lowPercent = config.LowPercent
+	}
+	if config.PollInterval < 0 {
+		return fmt.Errorf("[battery]: poll-interval can't be negative")
+	}
+	if config.PollInterval > 0 {
+		battery.pollInterval = config.PollInterval
+	}
+
+	if config.NotifyThresholds != nil {
+		for _, threshold := range config.NotifyThresholds {
+			if !isPercentage(threshold) {
+				return fmt.Errorf("[battery]: notify-thresholds must be between 0 and 100, got %d", threshold)
+			}
+		}
+		battery.notifyThresholds = config.NotifyThresholds
+	}
+
+	if (config.CriticalLevel > 0) != (len(config.CriticalCommand) > 0) {
+		return fmt.Errorf("[battery]: critical-level and critical-command only work together")
+	}
+	if !isPercentage(config.CriticalLevel) {
+		return fmt.Errorf("[battery]: critical-level must be between 0 and 100")
+	}
+	battery.criticalLevel = config.CriticalLevel
+	battery.criticalCommand = config.CriticalCommand
+	return nil
+}
+
+func (config WifiConfig) apply(wifi *wifiSignalProvider) error {
+	if config.LevelThresholds == nil {
+		return nil
+	}
+
+	if len(config.LevelThresholds) > len(signalBars)-1 {
+		return fmt.Errorf("[wifi]: level-thresholds can have at most %d percentages, one for each bar after the first", len(signalBars)-1)
+	}
+	for i, threshold := range config.LevelThresholds {
+		if threshold < 0 || threshold > 100 {
+			return fmt.Errorf("[wifi]: level-thresholds must be between 0 and 100, got %d", threshold)
+		}
+		if i > 0 && threshold <= config.LevelThresholds[i-1] {
+			return fmt.Errorf("[wifi]: level-thresholds must go from lowest to highest")
+		}
+	}
+	wifi.levelThresholds = config.LevelThresholds
+	return nil
+}
+
+// Without a property the block stays empty and logs that it needs one
+func (config DBusPropertyConfig) apply(dbusProperty *dbusPropertyProvider) error {
+	if config.dbusPropertyConfig == (dbusPropertyConfig{}) {
+		return nil
+	}
+
+	if err := dbusProperty.configure(config.dbusPropertyConfig); err != nil {
+		return fmt.Errorf("[dbus-property]: %w", err)
+	}
+	return nil
+}
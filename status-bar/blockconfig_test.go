@@ -0,0 +1,400 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The block names of the providers that the layout sends, with spacers as their width
+func layoutNames(layout blockLayout) []string {
+	names := []string{}
+	for _, provider := range layout.providers() {
+		if spacer, ok := provider.(spacerProvider); ok {
+			names = append(names, strings.Repeat("_", spacer.width))
+		} else {
+			names = append(names, provider.name())
+		}
+	}
+	return names
+}
+
+func TestBarConfigLayout(t *testing.T) {
+	providers := map[string]blockProvider{}
+	for _, name := range append([]string{"media", "cpu"}, defaultBlocks...) {
+		providers[name] = &fakeProvider{blockName: name}
+	}
+
+	tests := []struct {
+		name      string
+		config    BarConfig
+		want      string
+		wantError string
+	}{
+		{
+			name:   "defaults",
+			config: BarConfig{},
+			want:   strings.Join(defaultBlocks, " "),
+		},
+		{
+			name:   "right only",
+			config: BarConfig{Right: []string{"cpu", "time"}, LeftSpacerWidth: 3},
+			want:   "cpu time",
+		},
+		{
+			// An empty group is still a choice, it doesn't bring the defaults back
+			name:   "empty right",
+			config: BarConfig{Left: []string{"media"}, Right: []string{}},
+			want:   "media",
+		},
+		{
+			name:   "all groups",
+			config: BarConfig{Left: []string{"media"}, Center: []string{"time"}, Right: []string{"cpu"}, LeftSpacerWidth: 2, RightSpacerWidth: 3},
+			want:   "media __ time ___ cpu",
+		},
+		{
+			name:   "no center",
+			config: BarConfig{Left: []string{"media"}, Right: []string{"cpu"}, LeftSpacerWidth: 2, RightSpacerWidth: 3},
+			want:   "media __ cpu",
+		},
+		{
+			name:   "no spacers",
+			config: BarConfig{Left: []string{"media"}, Center: []string{"time"}, Right: []string{"cpu"}},
+			want:   "media time cpu",
+		},
+		{
+			name:      "unknown block",
+			config:    BarConfig{Center: []string{"clock"}},
+			wantError: `unknown block "clock"`,
+		},
+		{
+			name:      "in two groups",
+			config:    BarConfig{Left: []string{"time"}, Right: []string{"time"}},
+			wantError: `block "time" is in the config more than once`,
+		},
+		{
+			name:      "negative spacer",
+			config:    BarConfig{Right: []string{"time"}, RightSpacerWidth: -1},
+			wantError: "negative",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layout, err := test.config.layout(providers)
+			if test.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantError) {
+					t.Errorf("Got error %v, want %s", err, test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Join(layoutNames(layout), " "); got != test.want {
+				t.Errorf("Got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadBlockConfig(t *testing.T) {
+	setupConfigFiles(t, "", `
+[bar]
+right = ["time", "cpu"]
+
+[time]
+underline = 2
+border-color = "#BD93F9"
+
+[cpu]
+per-core = true
+border-bottom = 1
+`, "")
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers := map[string]blockProvider{
+		"time":  &fakeProvider{blockName: "time"},
+		"cpu":   &fakeProvider{blockName: "cpu"},
+		"media": &fakeProvider{blockName: "media"},
+	}
+	blockConfig, err := loadBlockConfig(config, providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.checkUnknown(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !blockConfig.CPU.PerCore {
+		t.Error("The cpu block's own option wasn't read")
+	}
+	if bottom := blockConfig.Blocks["cpu"].BorderBottom; bottom == nil || *bottom != 1 {
+		t.Error("The cpu block's style wasn't read")
+	}
+
+	layout, err := blockConfig.Bar.layout(providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockProviders := layout.providers()
+	settings := blockConfig.settingsInOrder(blockProviders, providers)
+	if settings[0].Underline != 2 || settings[1].BorderBottom == nil {
+		t.Errorf("Settings are in the wrong order: %+v", settings)
+	}
+}
+
+func TestLoadBlockConfigErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		wantError string
+	}{
+		{"bad style", "[time]\nunderline = -1", "[time]: underline can't be negative"},
+		{"wrong type", "[time]\nunderline = \"thick\"", `setting "time"`},
+		{"negative interval", "[time]\nmin-update-interval = \"-1s\"", "[time]: min-update-interval can't be negative"},
+		{"bad interval", "[time]\nmin-update-interval = \"soon\"", `setting "time"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupConfigFiles(t, "", test.config, "")
+			config, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = loadBlockConfig(config, map[string]blockProvider{"time": &fakeProvider{blockName: "time"}})
+			if err == nil || !strings.Contains(err.Error(), test.wantError) {
+				t.Errorf("Got error %v, want %s", err, test.wantError)
+			}
+		})
+	}
+}
+
+func TestMinUpdateInterval(t *testing.T) {
+	previousSettings := blockSettings
+	t.Cleanup(func() { blockSettings = previousSettings })
+	blockSettings = []BlockConfig{
+		{MinUpdateInterval: 2 * time.Second},
+		{},
+	}
+
+	tests := []struct {
+		index int
+		want  time.Duration
+	}{
+		{0, 2 * time.Second},
+		{1, defaultMinUpdateInterval},
+		{5, defaultMinUpdateInterval}, // No settings at all
+	}
+	for _, test := range tests {
+		if got := minUpdateInterval(test.index); got != test.want {
+			t.Errorf("minUpdateInterval(%d) = %v, want %v", test.index, got, test.want)
+		}
+	}
+}
+
+func TestVolumeConfigApply(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       VolumeConfig
+		wantMode     updateMode
+		wantInterval time.Duration
+		wantDBus     bool
+		wantError    string
+	}{
+		{name: "defaults", config: VolumeConfig{}, wantMode: updateModeEvent},
+		{name: "event", config: VolumeConfig{Mode: "event"}, wantMode: updateModeEvent},
+		{name: "poll", config: VolumeConfig{Mode: "poll"}, wantMode: updateModePoll},
+		{name: "poll interval", config: VolumeConfig{Mode: "poll", PollInterval: 10 * time.Second}, wantMode: updateModePoll, wantInterval: 10 * time.Second},
+		{name: "dbus", config: VolumeConfig{DBus: true}, wantMode: updateModeEvent, wantDBus: true},
+		{name: "unknown mode", config: VolumeConfig{Mode: "push"}, wantError: `unknown update mode "push"`},
+		{name: "interval without polling", config: VolumeConfig{PollInterval: 10 * time.Second}, wantError: `only used with mode = "poll"`},
+		{name: "interval too short", config: VolumeConfig{Mode: "poll", PollInterval: 100 * time.Millisecond}, wantError: "at least 1s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volume := volumeProvider{}
+			err := test.config.apply(&volume)
+			if test.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantError) {
+					t.Errorf("Got error %v, want %s", err, test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if volume.mode != test.wantMode || volume.pollInterval != test.wantInterval {
+				t.Errorf("Got mode %v every %v, want %v every %v", volume.mode, volume.pollInterval, test.wantMode, test.wantInterval)
+			}
+			if volume.useDBus != test.wantDBus {
+				t.Errorf("useDBus is %v, want %v", volume.useDBus, test.wantDBus)
+			}
+		})
+	}
+}
+
+func TestTimeConfigShowSeconds(t *testing.T) {
+	setupConfigFiles(t, "", "[time]\nshow-seconds = true", "")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockConfig, err := loadBlockConfig(config, map[string]blockProvider{"time": &timeMonitor{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeProvider := timeMonitor{now: time.Date(2026, 10, 15, 9, 5, 7, 0, time.UTC)}
+	blockConfig.Time.apply(&timeProvider)
+	if block := timeProvider.createBlock(); block.FullText != "Thu Oct 15, 2026 09:05:07" {
+		t.Errorf("Block is %q, want the seconds", block.FullText)
+	}
+}
+
+func TestBatteryConfigApply(t *testing.T) {
+	defaults := func() batteryProvider {
+		return batteryProvider{notifyThresholds: []int{15, 5}, lowPercent: 10, pollInterval: 30 * time.Second}
+	}
+
+	tests := []struct {
+		name      string
+		config    BatteryConfig
+		want      func(battery *batteryProvider) bool
+		wantError string
+	}{
+		{
+			name:   "defaults",
+			config: BatteryConfig{},
+			want: func(battery *batteryProvider) bool {
+				return battery.lowPercent == 10 && len(battery.notifyThresholds) == 2 && battery.criticalCommand == nil
+			},
+		},
+		{
+			name:   "thresholds",
+			config: BatteryConfig{NotifyThresholds: []int{20, 10, 5}, LowPercent: 15, PollInterval: time.Minute},
+			want: func(battery *batteryProvider) bool {
+				return len(battery.notifyThresholds) == 3 && battery.lowPercent == 15 && battery.pollInterval == time.Minute
+			},
+		},
+		{
+			name:   "no notifications",
+			config: BatteryConfig{NotifyThresholds: []int{}},
+			want: func(battery *batteryProvider) bool {
+				return len(battery.notifyThresholds) == 0
+			},
+		},
+		{
+			name:   "critical",
+			config: BatteryConfig{CriticalLevel: 3, CriticalCommand: []string{"systemctl", "suspend"}},
+			want: func(battery *batteryProvider) bool {
+				return battery.criticalLevel == 3 && len(battery.criticalCommand) == 2
+			},
+		},
+		{name: "level without command", config: BatteryConfig{CriticalLevel: 3}, wantError: "only work together"},
+		{name: "command without level", config: BatteryConfig{CriticalCommand: []string{"poweroff"}}, wantError: "only work together"},
+		{name: "threshold over 100", config: BatteryConfig{NotifyThresholds: []int{150}}, wantError: "got 150"},
+		{name: "negative low", config: BatteryConfig{LowPercent: -1}, wantError: "low-percent"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			battery := defaults()
+			err := test.config.apply(&battery)
+			if test.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantError) {
+					t.Errorf("Got error %v, want %s", err, test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.want(&battery) {
+				t.Errorf("Got %+v", battery)
+			}
+		})
+	}
+}
+
+func TestTemperatureUnitConfig(t *testing.T) {
+	setupConfigFiles(t, "", `
+[temperature]
+unit = "fahrenheit"
+
+[weather]
+unit = "F"
+`, "")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockConfig, err := loadBlockConfig(config, map[string]blockProvider{
+		"temperature": &temperatureProvider{},
+		"weather":     &weatherProvider{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	temperature := temperatureProvider{}
+	if err := blockConfig.Temperature.apply(&temperature); err != nil {
+		t.Fatal(err)
+	}
+	weather := weatherProvider{}
+	if err := blockConfig.Weather.apply(&weather); err != nil {
+		t.Fatal(err)
+	}
+	if temperature.unit != unitFahrenheit || weather.unit != unitFahrenheit {
+		t.Errorf("Units are %v and %v, want Fahrenheit", temperature.unit, weather.unit)
+	}
+
+	if err := (TemperatureConfig{Unit: "kelvin"}).apply(&temperature); err == nil || !strings.Contains(err.Error(), "[temperature]") {
+		t.Errorf("Got error %v for kelvin", err)
+	}
+}
+
+func TestWifiConfigApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []int
+		percent    int
+		wantLevel  int
+		wantError  string
+	}{
+		{name: "defaults", percent: 60, wantLevel: 3},
+		{name: "higher thresholds", thresholds: []int{30, 70, 90}, percent: 60, wantLevel: 2},
+		{name: "fewer bars", thresholds: []int{50}, percent: 99, wantLevel: 2},
+		{name: "one bar", thresholds: []int{}, percent: 99, wantLevel: 1},
+		{name: "too many", thresholds: []int{10, 20, 30, 40}, wantError: "at most 3"},
+		{name: "out of order", thresholds: []int{50, 25}, wantError: "lowest to highest"},
+		{name: "over 100", thresholds: []int{101}, wantError: "got 101"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wifi := wifiSignalProvider{levelThresholds: []int{25, 50, 75}, percent: test.percent}
+			err := (WifiConfig{LevelThresholds: test.thresholds}).apply(&wifi)
+			if test.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantError) {
+					t.Errorf("Got error %v, want %s", err, test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if level := wifi.signalLevel(); level != test.wantLevel {
+				t.Errorf("Level is %d, want %d", level, test.wantLevel)
+			}
+		})
+	}
+}
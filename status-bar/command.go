@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+const defaultCommandTimeout = 5 * time.Second
+
+// Base for blocks that show the parsed output of a command and run it again when a signal
+// arrives, e.g. the volume after a key binding sends VOLUME_CHANGED_SIGNAL. Embed it, set
+// command and parse, and render value in createBlock. The command also runs again on every
+// poll interval, whenever events sends and when a refresh is requested. If it fails or can't
+// be parsed the last value is kept. Anything else that changes the provider's state, e.g. a
+// click handler, goes through runOnMonitor.
+type commandProvider[T comparable] struct {
+	refreshTrigger
+	monitorActions
+
+	value T
+	valid bool // False until the first successful run
+
+	command       []string
+	parse         func(output []byte) (T, error)
+	timeout       time.Duration          // Defaults to defaultCommandTimeout
+	refreshSignal os.Signal              // Optional
+	pollInterval  time.Duration          // Optional
+	events        func() <-chan struct{} // Optional, e.g. a long running subscription
+	fetch         func() (T, error)      // Optional, replaces running the command. See runCommand.
+}
+
+// Click handlers run in their own goroutine, so ones that change a provider's state hand the
+// change to the monitor goroutine instead of racing it
+type monitorActions struct {
+	once    sync.Once
+	actions chan func()
+}
+
+func (ma *monitorActions) actionRequests() <-chan func() {
+	ma.once.Do(func() {
+		ma.actions = make(chan func())
+	})
+	return ma.actions
+}
+
+// Blocks until the monitor is between updates
+func (ma *monitorActions) runOnMonitor(action func()) {
+	ma.actionRequests()
+	ma.actions <- action
+}
+
+// Providers that set fetch can call this to fall back to the command
+func (cp *commandProvider[T]) runCommand() (T, error) {
+	var zero T
+	if len(cp.command) == 0 {
+		return zero, errors.New("no command")
+	}
+
+	output, err := commandOutput(cp.timeout, cp.command...)
+	if err != nil {
+		return zero, err
+	}
+
+	return cp.parse(output)
+}
+
+// Runs a command and returns its stdout. It is killed after the timeout, or defaultCommandTimeout
+// if the timeout isn't positive.
+func commandOutput(timeout time.Duration, command ...string) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return exec.CommandContext(ctx, command[0], command[1:]...).Output()
+}
+
+// Returns true if the value changed
+func (cp *commandProvider[T]) update() bool {
+	fetch := cp.fetch
+	if fetch == nil {
+		fetch = cp.runCommand
+	}
+
+	value, err := fetch()
+	if err != nil {
+		logger.Println("Could not update", cp.command, err)
+		return false
+	}
+
+	if cp.valid && value == cp.value {
+		return false
+	}
+	cp.value, cp.valid = value, true
+	return true
+}
+
+func (cp *commandProvider[T]) monitor(changeChan chan<- blockChangedMessage, index int) {
+	// Nil channels are never ready, so only the options that are set do anything
+	var signals chan os.Signal
+	if cp.refreshSignal != nil {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, cp.refreshSignal)
+	}
+
+	var pollTicks <-chan time.Time
+	if cp.pollInterval > 0 {
+		ticker := time.NewTicker(cp.pollInterval)
+		defer ticker.Stop()
+		pollTicks = ticker.C
+	}
+
+	var events <-chan struct{}
+	if cp.events != nil {
+		events = cp.events()
+	}
+
+	for {
+		if cp.update() {
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+
+		select {
+		case <-signals:
+		case <-pollTicks:
+		case _, isOpen := <-events:
+			if !isOpen {
+				events = nil
+			}
+		case <-cp.refreshRequests():
+		case action := <-cp.actionRequests():
+			// The value is read again afterwards, since the action probably changed it
+			action()
+		}
+	}
+}
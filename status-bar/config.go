@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A variable so that tests can point it somewhere else
+var systemConfigPath = "/etc/status-bar/config.toml"
+
+// Top level keys that are flag names set the flag to their value, e.g.
+//
+//	urgent-first = true
+//	hide-duration = "30m"
+//	glyph = ["U+F0E7=AC", "U+F0599=night"]
+//
+// A list sets a flag that can be repeated once per item. The other top level keys are the
+// blocks and their options, see blockconfig.go. Settings are taken from, highest precedence
+// first:
+//
+//  1. Flags on the command line
+//  2. $XDG_CONFIG_HOME/status-bar/config.toml, or ~/.config/status-bar/config.toml if
+//     XDG_CONFIG_HOME isn't set or has no config
+//  3. /etc/status-bar/config.toml, for distribution defaults
+//
+// The files are merged by their top level keys, so a key in the user's config replaces the
+// system's value for it completely, including lists and whole sections like [weather].
+func userConfigPaths() []string {
+	paths := []string{}
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "status-bar", "config.toml"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "status-bar", "config.toml"))
+	}
+	return paths
+}
+
+type configFile struct {
+	path     string
+	metadata toml.MetaData
+}
+
+// A top level value, decoded once it is known what it sets
+type configSetting struct {
+	value toml.Primitive
+	file  *configFile
+}
+
+// The merged config files
+type fileConfig struct {
+	settings map[string]configSetting
+	used     map[string]bool // Top level keys that were decoded, the rest are unknown
+}
+
+// A missing file is an empty config
+func readConfigFile(path string) (map[string]configSetting, bool, error) {
+	var values map[string]toml.Primitive
+	metadata, err := toml.DecodeFile(path, &values)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	file := &configFile{path, metadata}
+	settings := make(map[string]configSetting, len(values))
+	for key, value := range values {
+		settings[key] = configSetting{value, file}
+	}
+	return settings, true, nil
+}
+
+// Later configs replace the keys of earlier ones
+func mergeConfigs(configs ...map[string]configSetting) *fileConfig {
+	merged := &fileConfig{
+		settings: make(map[string]configSetting),
+		used:     make(map[string]bool),
+	}
+	for _, config := range configs {
+		for key, setting := range config {
+			merged.settings[key] = setting
+		}
+	}
+	return merged
+}
+
+func loadConfig() (*fileConfig, error) {
+	system, _, err := readConfigFile(systemConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var user map[string]configSetting
+	for _, path := range userConfigPaths() {
+		var exists bool
+		user, exists, err = readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			break
+		}
+	}
+
+	return mergeConfigs(system, user), nil
+}
+
+// Decodes the top level key into target, which is left alone if no config has the key
+func (config *fileConfig) decode(key string, target any) error {
+	setting, exists := config.settings[key]
+	if !exists {
+		return nil
+	}
+
+	config.used[key] = true
+	err := setting.file.metadata.PrimitiveDecode(setting.value, target)
+	if err != nil {
+		return fmt.Errorf("%s: setting %q: %w", setting.file.path, key, err)
+	}
+	return nil
+}
+
+// Settings that nothing decoded are an error so that typos don't go unnoticed. Only called once
+// everything that reads the config has.
+func (config *fileConfig) checkUnknown() error {
+	// Sorted so that errors come out the same way every time
+	keys := make([]string, 0, len(config.settings))
+	for key := range config.settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	checkedFiles := make(map[*configFile]bool)
+	for _, key := range keys {
+		setting := config.settings[key]
+		if !config.used[key] {
+			return fmt.Errorf("%s: unknown setting %q", setting.file.path, key)
+		}
+
+		if checkedFiles[setting.file] {
+			continue
+		}
+		checkedFiles[setting.file] = true
+
+		// Keys inside sections, e.g. a misspelled option of a block. Sections that the other
+		// file replaced don't matter.
+		for _, undecoded := range setting.file.metadata.Undecoded() {
+			if config.settings[undecoded[0]].file == setting.file {
+				return fmt.Errorf("%s: unknown setting %q", setting.file.path, undecoded.String())
+			}
+		}
+	}
+	return nil
+}
+
+// Sets every flag that is in the config and wasn't given on the command line. Must be called
+// after flags.Parse.
+func applyConfig(flags *flag.FlagSet, config *fileConfig) error {
+	givenFlags := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		givenFlags[f.Name] = true
+	})
+
+	keys := make([]string, 0, len(config.settings))
+	for key := range config.settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// Sections are blocks, some of which share a name with a flag like idle
+		if flags.Lookup(key) == nil || config.settings[key].file.metadata.Type(key) == "Hash" {
+			continue
+		}
+
+		var value any
+		err := config.decode(key, &value)
+		if err != nil {
+			return err
+		}
+		if givenFlags[key] {
+			continue
+		}
+
+		values, err := configFlagValues(value)
+		if err != nil {
+			return fmt.Errorf("setting %q: %w", key, err)
+		}
+
+		for _, value := range values {
+			err = flags.Set(key, value)
+			if err != nil {
+				return fmt.Errorf("setting %q: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Strings are used as they are, numbers and booleans as they are written and lists once per item
+func configFlagValues(value any) ([]string, error) {
+	switch value := value.(type) {
+	case []any:
+		values := []string{}
+		for _, item := range value {
+			itemValues, err := configFlagValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	case string:
+		return []string{value}, nil
+	case bool:
+		return []string{strconv.FormatBool(value)}, nil
+	case int64:
+		return []string{strconv.FormatInt(value, 10)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(value, 'f', -1, 64)}, nil
+	}
+	return nil, fmt.Errorf("expected a string, number, boolean or list, got %v", value)
+}
+
+func exitOnConfigError(err error) {
+	if err != nil {
+		logger.Println("Could not load config", err)
+		fmt.Fprintln(os.Stderr, "Could not load config", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Points the system, XDG and home configs at a temporary directory, writing the ones that aren't ""
+func setupConfigFiles(t *testing.T, system, xdg, home string) {
+	t.Helper()
+	directory := t.TempDir()
+
+	previousSystemConfigPath := systemConfigPath
+	t.Cleanup(func() { systemConfigPath = previousSystemConfigPath })
+	systemConfigPath = filepath.Join(directory, "etc", "status-bar", "config.toml")
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(directory, "xdg"))
+	t.Setenv("HOME", filepath.Join(directory, "home"))
+
+	files := map[string]string{
+		systemConfigPath: system,
+		filepath.Join(directory, "xdg", "status-bar", "config.toml"):             xdg,
+		filepath.Join(directory, "home", ".config", "status-bar", "config.toml"): home,
+	}
+	for path, contents := range files {
+		if contents != "" {
+			writeConfig(t, path, contents)
+		}
+	}
+}
+
+func decodeString(t *testing.T, config *fileConfig, key string) string {
+	t.Helper()
+	var value string
+	if err := config.decode(key, &value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name              string
+		system, xdg, home string
+		want              map[string]string
+	}{
+		{
+			name: "nothing",
+			want: map[string]string{"a": "", "b": ""},
+		},
+		{
+			name:   "system only",
+			system: `a = "system"`,
+			want:   map[string]string{"a": "system"},
+		},
+		{
+			name:   "XDG overrides system",
+			system: "a = \"system\"\nb = \"system\"",
+			xdg:    `a = "xdg"`,
+			want:   map[string]string{"a": "xdg", "b": "system"},
+		},
+		{
+			name:   "home without XDG",
+			system: "a = \"system\"\nb = \"system\"",
+			home:   `b = "home"`,
+			want:   map[string]string{"a": "system", "b": "home"},
+		},
+		{
+			// Only the first user config that exists is read
+			name: "XDG over home",
+			xdg:  `a = "xdg"`,
+			home: "a = \"home\"\nb = \"home\"",
+			want: map[string]string{"a": "xdg", "b": ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupConfigFiles(t, test.system, test.xdg, test.home)
+
+			config, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range test.want {
+				if got := decodeString(t, config, key); got != want {
+					t.Errorf("%s is %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// Sections are replaced as a whole rather than merged key by key
+func TestMergeConfigsReplacesSections(t *testing.T) {
+	setupConfigFiles(t, "[weather]\nurl = \"https://example.com\"\nupdate-interval = \"5m\"", `
+[weather]
+update-interval = "30m"
+`, "")
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var weather WeatherConfig
+	if err := config.decode("weather", &weather); err != nil {
+		t.Fatal(err)
+	}
+	want := WeatherConfig{UpdateInterval: 30 * time.Minute}
+	if weather != want {
+		t.Errorf("Got %+v, want %+v", weather, want)
+	}
+}
+
+func TestCheckUnknown(t *testing.T) {
+	tests := []struct {
+		name         string
+		system, user string
+		wantError    string
+	}{
+		{"known", `[weather]`, `[weather]`, ""},
+		{"unknown top level key", "", `colour = "red"`, `unknown setting "colour"`},
+		{"unknown section", "", `[wether]`, `unknown setting "wether"`},
+		{"unknown option", "", "[weather]\nuri = \"x\"", `unknown setting "weather.uri"`},
+		{"unknown option in the system config", "[weather]\nuri = \"x\"", "", `unknown setting "weather.uri"`},
+		// The user's section replaces the system's, so its typo never takes effect
+		{"unknown option in a replaced section", "[weather]\nuri = \"x\"", "[weather]\nurl = \"x\"", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupConfigFiles(t, test.system, test.user, "")
+
+			config, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var weather WeatherConfig
+			if err := config.decode("weather", &weather); err != nil {
+				t.Fatal(err)
+			}
+
+			err = config.checkUnknown()
+			if test.wantError == "" {
+				if err != nil {
+					t.Errorf("Got error %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.wantError) {
+				t.Errorf("Got error %v, want %s", err, test.wantError)
+			}
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	setupConfigFiles(t, `
+urgent-first = true
+hide-duration = "1h"
+`, `
+hide-duration = "30m"
+edge-margin = 4
+glyph = ["a", "b"]
+
+[idle]
+`, "")
+
+	flags := flag.NewFlagSet("status-bar", flag.ContinueOnError)
+	urgentFirst := flags.Bool("urgent-first", false, "")
+	hideDuration := flags.Duration("hide-duration", 0, "")
+	edgeMargin := flags.Int("edge-margin", -1, "")
+	idle := flags.Bool("idle", false, "")
+	var glyphs []string
+	flags.Func("glyph", "", func(value string) error {
+		glyphs = append(glyphs, value)
+		return nil
+	})
+
+	// The command line wins over both files
+	if err := flags.Parse([]string{"-edge-margin", "8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(flags, config); err != nil {
+		t.Fatal(err)
+	}
+
+	if !*urgentFirst {
+		t.Error("urgent-first from the system config wasn't set")
+	}
+	if *hideDuration != 30*time.Minute {
+		t.Errorf("hide-duration is %v, want the user's 30m", *hideDuration)
+	}
+	if *edgeMargin != 8 {
+		t.Errorf("edge-margin is %d, want 8 from the command line", *edgeMargin)
+	}
+	if !reflect.DeepEqual(glyphs, []string{"a", "b"}) {
+		t.Errorf("glyph was set to %v", glyphs)
+	}
+	if *idle {
+		t.Error("The [idle] section set the idle flag")
+	}
+
+	// The section is left for the block
+	if config.used["idle"] {
+		t.Error("The [idle] section was taken as a flag")
+	}
+}
+
+func TestConfigFlagValues(t *testing.T) {
+	tests := []struct {
+		value   any
+		want    []string
+		wantErr bool
+	}{
+		{"30m", []string{"30m"}, false},
+		{true, []string{"true"}, false},
+		{int64(-4), []string{"-4"}, false},
+		{0.5, []string{"0.5"}, false},
+		{[]any{"a", int64(1)}, []string{"a", "1"}, false},
+		{[]any{}, []string{}, false},
+		{map[string]any{"a": "b"}, nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := configFlagValues(test.value)
+		if (err != nil) != test.wantErr || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("configFlagValues(%#v) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+}
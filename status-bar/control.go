@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Other programs talk to a running status bar over a unix socket, one command per connection.
+// A command is a single line of space separated words and the reply is a single line that
+// starts with "ok" or "error:", or is the JSON asked for.
+//
+//	refresh <block name>  Fetch the block's value again now instead of at its next update.
+//	                      The blocks that can be refreshed are weather, network, tasks, updates,
+//	                      rss, torrents and volume.
+//	notify <text>         Show the text in the message block for a while.
+//	idle                  Start counting down to the screen locking in the idle block.
+//	active                Stop the count and hide the idle block.
+//	stats                 Reply with the bar's update counts, errors and render times as JSON.
+//	                      Only collected when the bar runs with -collect-stats.
+func controlSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = os.TempDir()
+	}
+	return filepath.Join(runtimeDir, "status-bar.sock")
+}
+
+type controlRequest struct {
+	command string
+	args    []string
+	reply   chan string
+}
+
+// Requests are handled by the main loop so that they don't race with rendering
+func listenForControl(requests chan<- controlRequest) {
+	path := controlSocketPath()
+
+	// Left behind if the last instance was killed
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		logger.Println("Could not open control socket", err)
+		return
+	}
+
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				logger.Println("Control socket closed", err)
+				return
+			}
+
+			go func() {
+				defer connection.Close()
+
+				line, err := bufio.NewReader(connection).ReadString('\n')
+				if err != nil && line == "" {
+					return
+				}
+
+				fields := strings.Fields(line)
+				if len(fields) == 0 {
+					fmt.Fprintln(connection, "error: empty command")
+					return
+				}
+
+				request := controlRequest{
+					command: fields[0],
+					args:    fields[1:],
+					reply:   make(chan string, 1),
+				}
+				requests <- request
+				fmt.Fprintln(connection, <-request.reply)
+			}()
+		}
+	}()
+}
+
+// Used by the client side, e.g. status-bar -refresh weather
+func sendControlCommand(command string) (string, error) {
+	connection, err := net.Dial("unix", controlSocketPath())
+	if err != nil {
+		return "", fmt.Errorf("could not reach the status bar, is it running? %w", err)
+	}
+	defer connection.Close()
+
+	_, err = fmt.Fprintln(connection, command)
+	if err != nil {
+		return "", err
+	}
+
+	reply, err := bufio.NewReader(connection).ReadString('\n')
+	reply = strings.TrimSpace(reply)
+	if err != nil && reply == "" {
+		return "", err
+	}
+
+	if message, isError := strings.CutPrefix(reply, "error: "); isError {
+		return "", errors.New(message)
+	}
+	return reply, nil
+}
+
+// Providers that can fetch their value again right away, instead of waiting for their next poll
+// or event, implement this. It must not block.
+type refreshableProvider interface {
+	requestRefresh()
+}
+
+// Embedded by providers to wake their monitor early. Their monitor selects on refreshRequests.
+type refreshTrigger struct {
+	once     sync.Once
+	requests chan struct{}
+}
+
+func (rt *refreshTrigger) refreshRequests() <-chan struct{} {
+	rt.once.Do(func() {
+		rt.requests = make(chan struct{}, 1)
+	})
+	return rt.requests
+}
+
+func (rt *refreshTrigger) requestRefresh() {
+	rt.refreshRequests()
+	select {
+	case rt.requests <- struct{}{}:
+	default: // Already pending
+	}
+}
+
+func handleControlRequest(request controlRequest, blockProviders []blockProvider, providersByName map[blockKey]int) string {
+	switch request.command {
+	case "refresh":
+		if len(request.args) != 1 {
+			return "error: usage: refresh <block name>"
+		}
+
+		index, exists := providersByName[blockKey{name: request.args[0]}]
+		if !exists {
+			return fmt.Sprintf("error: no block named %q", request.args[0])
+		}
+
+		refreshable, ok := blockProviders[index].(refreshableProvider)
+		if !ok {
+			return fmt.Sprintf("error: %q can't be refreshed", request.args[0])
+		}
+
+		refreshable.requestRefresh()
+		return "ok"
+
+	case "notify":
+		if len(request.args) == 0 {
+			return "error: usage: notify <text>"
+		}
+
+		index, exists := providersByName[blockKey{name: "message"}]
+		if !exists {
+			return "error: no message block is configured"
+		}
+
+		notifiable, ok := blockProviders[index].(notifiableProvider)
+		if !ok {
+			return "error: the message block can't show messages"
+		}
+
+		notifiable.showMessage(strings.Join(request.args, " "))
+		return "ok"
+
+	case "idle", "active":
+		index, exists := providersByName[blockKey{name: "idle"}]
+		if !exists {
+			return "error: no idle block is configured"
+		}
+
+		idleAware, ok := blockProviders[index].(idleAwareProvider)
+		if !ok {
+			return "error: the idle block can't count down"
+		}
+
+		idleAware.setIdle(request.command == "idle")
+		return "ok"
+
+	case "stats":
+		if !collectStats {
+			return "error: stats aren't collected, start the bar with -collect-stats"
+		}
+
+		report, err := stats.report(blockProviders)
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		return string(report)
+	}
+
+	return fmt.Sprintf("error: unknown command %q", request.command)
+}
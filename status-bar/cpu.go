@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cumulative ticks since boot from /proc/stat
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+// Returns the aggregate times from the "cpu" line and then one entry per "cpuN" line
+func readProcStat() (cpuTimes, []cpuTimes, error) {
+	contents, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, []cpuTimes{}, err
+	}
+	return parseProcStat(string(contents))
+}
+
+func parseProcStat(contents string) (cpuTimes, []cpuTimes, error) {
+	var aggregate cpuTimes
+	cores := []cpuTimes{}
+
+	foundAggregate := false
+	for _, line := range strings.Split(contents, "\n") {
+		// cpu0 4705 356 584 3699 23 23 0 0 0 0
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+
+		var times cpuTimes
+		// user nice system idle iowait irq softirq steal. guest time is already counted in user.
+		valueFields := fields[1:]
+		if len(valueFields) > 8 {
+			valueFields = valueFields[:8]
+		}
+		for i, field := range valueFields {
+			ticks, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return aggregate, cores, err
+			}
+
+			times.total += ticks
+			if i == 3 || i == 4 {
+				times.idle += ticks
+			}
+		}
+
+		if fields[0] == "cpu" {
+			aggregate = times
+			foundAggregate = true
+		} else {
+			cores = append(cores, times)
+		}
+	}
+
+	if !foundAggregate {
+		return aggregate, cores, errors.New("no cpu line in /proc/stat")
+	}
+
+	return aggregate, cores, nil
+}
+
+// Percentage of time that wasn't idle between two readings
+func cpuUsage(previous, current cpuTimes) float64 {
+	if current.total <= previous.total {
+		return 0
+	}
+	totalDelta := current.total - previous.total
+
+	idleDelta := current.idle - previous.idle
+	if current.idle < previous.idle || idleDelta > totalDelta {
+		idleDelta = totalDelta
+	}
+
+	return float64(totalDelta-idleDelta) * 100 / float64(totalDelta)
+}
+
+var sparklineGlyphs = []rune("▁▂▃▄▅▆▇█")
+
+// Maps 0-100% to one of the sparkline glyphs
+func sparklineLevel(percent float64) int {
+	level := int(percent * float64(len(sparklineGlyphs)) / 100)
+	if level < 0 {
+		return 0
+	}
+	if level >= len(sparklineGlyphs) {
+		return len(sparklineGlyphs) - 1
+	}
+	return level
+}
+
+// The sparkline level of each core between two readings. Cores that weren't in the previous
+// reading, e.g. ones that were just brought online, start at the lowest level.
+func coreLevels(previous, current []cpuTimes) []int {
+	levels := make([]int, len(current))
+	for i := range current {
+		if i < len(previous) {
+			levels[i] = sparklineLevel(cpuUsage(previous[i], current[i]))
+		}
+	}
+	return levels
+}
+
+func equalLevels(first, second []int) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A sparkline with a bar for each core. The cpu block shows the usage as a number instead.
+type cpuCoresProvider struct {
+	levels []int
+
+	interval time.Duration
+}
+
+func (cc *cpuCoresProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	interval := cc.interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	_, previous, err := readProcStat()
+	if err != nil {
+		logger.Println("Can't read CPU usage", err)
+		return
+	}
+
+	for {
+		time.Sleep(interval)
+
+		_, current, err := readProcStat()
+		if err != nil {
+			logger.Println("Can't read CPU usage", err)
+			continue
+		}
+
+		levels := coreLevels(previous, current)
+		previous = current
+
+		if !equalLevels(levels, cc.levels) {
+			cc.levels = levels
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+}
+
+func (cc *cpuCoresProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	if len(cc.levels) > 0 {
+		graph := make([]rune, len(cc.levels))
+		busiest := 0
+		for i, level := range cc.levels {
+			graph[i] = sparklineGlyphs[level]
+			if level > busiest {
+				busiest = level
+			}
+		}
+		block.FullText = "󰻠 " + string(graph)
+		block.ShortText = string(sparklineGlyphs[busiest])
+	}
+
+	return block
+}
+
+func (cc *cpuCoresProvider) name() string {
+	return "cpu-cores"
+}
+
+func (cc *cpuCoresProvider) respondToClick(event clickEvent) {}
+
+// Above these the CPU usage block turns yellow and then red
+const (
+	cpuBusyPercent      = 70
+	cpuSaturatedPercent = 90
+
+	cpuBusyColor      = "#F1FA8C"
+	cpuSaturatedColor = "#FF5555"
+)
+
+// Shows the usage of all cores together as a percentage, e.g. CPU 42%, and optionally of each
+// core after it. Hidden where there is no /proc/stat.
+type cpuProvider struct {
+	percent      int
+	corePercents []int
+	valid        bool
+
+	perCore        bool
+	sampleInterval time.Duration // Defaults to a second
+}
+
+func (cp *cpuProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	sampleInterval := cp.sampleInterval
+	if sampleInterval <= 0 {
+		sampleInterval = 1 * time.Second
+	}
+
+	previous, previousCores, err := readProcStat()
+	if err != nil {
+		// Not Linux, or /proc isn't mounted. It won't appear later.
+		logger.Println("Can't read CPU usage", err)
+		return
+	}
+
+	for {
+		time.Sleep(sampleInterval)
+
+		current, currentCores, err := readProcStat()
+		if err != nil {
+			logger.Println("Can't read CPU usage", err)
+			continue
+		}
+
+		// cpuUsage is 0 when no ticks passed, e.g. for a sample interval shorter than a tick
+		percent := int(math.Round(cpuUsage(previous, current)))
+		changed := !cp.valid || percent != cp.percent
+
+		var corePercents []int
+		if cp.perCore {
+			corePercents = make([]int, len(currentCores))
+			changed = changed || len(currentCores) != len(cp.corePercents)
+			for i := range currentCores {
+				if i < len(previousCores) {
+					corePercents[i] = int(math.Round(cpuUsage(previousCores[i], currentCores[i])))
+				}
+				if !changed && corePercents[i] != cp.corePercents[i] {
+					changed = true
+				}
+			}
+		}
+		previous, previousCores = current, currentCores
+
+		if changed {
+			cp.percent, cp.corePercents, cp.valid = percent, corePercents, true
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+}
+
+func (cp *cpuProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	if !cp.valid {
+		return block
+	}
+
+	block.FullText = fmt.Sprintf("CPU %d%%", cp.percent)
+	block.ShortText = fmt.Sprintf("%d%%", cp.percent)
+	if len(cp.corePercents) > 0 {
+		cores := make([]string, len(cp.corePercents))
+		for i, percent := range cp.corePercents {
+			cores[i] = strconv.Itoa(percent)
+		}
+		block.FullText += " (" + strings.Join(cores, " ") + ")"
+	}
+
+	if cp.percent > cpuSaturatedPercent {
+		block.Color = cpuSaturatedColor
+	} else if cp.percent > cpuBusyPercent {
+		block.Color = cpuBusyColor
+	}
+
+	return block
+}
+
+func (cp *cpuProvider) name() string {
+	return "cpu"
+}
+
+func (cp *cpuProvider) respondToClick(event clickEvent) {}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSparklineLevel(t *testing.T) {
+	tests := []struct {
+		percent float64
+		want    int
+	}{
+		{0, 0},
+		{12.4, 0},
+		{12.5, 1},
+		{49.9, 3},
+		{50, 4},
+		{87.5, 7},
+		{99.9, 7},
+		{100, 7},
+		{-5, 0},  // Counters that went backwards
+		{150, 7}, // Rounding past 100
+	}
+
+	for _, test := range tests {
+		if got := sparklineLevel(test.percent); got != test.want {
+			t.Errorf("sparklineLevel(%v) = %d, want %d", test.percent, got, test.want)
+		}
+	}
+}
+
+func TestCPUUsage(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current cpuTimes
+		want              float64
+	}{
+		{"idle", cpuTimes{idle: 100, total: 200}, cpuTimes{idle: 200, total: 300}, 0},
+		{"busy", cpuTimes{idle: 100, total: 200}, cpuTimes{idle: 100, total: 300}, 100},
+		{"quarter", cpuTimes{idle: 100, total: 200}, cpuTimes{idle: 175, total: 300}, 25},
+		{"no ticks passed", cpuTimes{idle: 100, total: 200}, cpuTimes{idle: 100, total: 200}, 0},
+		{"total went backwards", cpuTimes{idle: 100, total: 200}, cpuTimes{idle: 50, total: 100}, 0},
+		{"idle went backwards", cpuTimes{idle: 100, total: 200}, cpuTimes{idle: 90, total: 300}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := cpuUsage(test.previous, test.current); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseProcStat(t *testing.T) {
+	// user nice system idle iowait irq softirq steal guest guest_nice
+	contents := `cpu  10 1 5 80 4 0 0 0 3 0
+cpu0 6 1 3 30 0 0 0 0 3 0
+cpu1 4 0 2 50 4 0 0 0 0 0
+intr 12345 0 0
+ctxt 67890
+`
+
+	aggregate, cores, err := parseProcStat(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// guest time is already part of user, so it isn't counted twice
+	if want := (cpuTimes{idle: 84, total: 100}); aggregate != want {
+		t.Errorf("aggregate = %+v, want %+v", aggregate, want)
+	}
+	wantCores := []cpuTimes{{idle: 30, total: 40}, {idle: 54, total: 60}}
+	if !reflect.DeepEqual(cores, wantCores) {
+		t.Errorf("cores = %+v, want %+v", cores, wantCores)
+	}
+
+	if _, _, err := parseProcStat("intr 12345 0 0\n"); err == nil {
+		t.Error("Expected an error without a cpu line")
+	}
+}
+
+func TestCoreLevels(t *testing.T) {
+	previous := []cpuTimes{{idle: 100, total: 200}, {idle: 100, total: 200}}
+	current := []cpuTimes{
+		{idle: 200, total: 300}, // Idle
+		{idle: 100, total: 300}, // Busy
+		{idle: 10, total: 20},   // Came online since the last reading
+	}
+
+	if got, want := coreLevels(previous, current), []int{0, 7, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Where a DBus property lives, set in [dbus-property], e.g. the charge of the laptop battery
+// according to UPower:
+//
+//	[dbus-property]
+//	bus = "system"
+//	service = "org.freedesktop.UPower"
+//	path = "/org/freedesktop/UPower/devices/DisplayDevice"
+//	interface = "org.freedesktop.UPower.Device"
+//	property = "Percentage"
+//	format = '{{printf "%.0f" .Value}}%'
+type dbusPropertyConfig struct {
+	Bus       string `toml:"bus"` // "session" or "system", defaults to session
+	Service   string `toml:"service"`
+	Path      string `toml:"path"`
+	Interface string `toml:"interface"`
+	Property  string `toml:"property"`
+	Format    string `toml:"format"` // text/template, the property is {{.Value}}. Defaults to {{.Value}}.
+}
+
+// Shows any DBus property and updates when it emits PropertiesChanged. Only available in builds
+// with -tags dbus. The block is hidden while the property can't be read, e.g. when its service
+// isn't running.
+type dbusPropertyProvider struct {
+	oneShotProvider
+	config dbusPropertyConfig
+	format *template.Template // Nil until configured
+}
+
+func newDBusPropertyProvider() *dbusPropertyProvider {
+	provider := &dbusPropertyProvider{}
+	provider.fetch = provider.render
+	provider.updates = func() (<-chan struct{}, error) {
+		return watchDBusProperty(provider.config)
+	}
+	return provider
+}
+
+// Must be called before the block's monitor starts
+func (dp *dbusPropertyProvider) configure(config dbusPropertyConfig) error {
+	switch config.Bus {
+	case "", "session", "system":
+	default:
+		return fmt.Errorf("unknown bus %q, expected session or system", config.Bus)
+	}
+
+	required := []struct{ key, value string }{
+		{"service", config.Service},
+		{"path", config.Path},
+		{"interface", config.Interface},
+		{"property", config.Property},
+	}
+	for _, setting := range required {
+		if setting.value == "" {
+			return fmt.Errorf("%s is missing", setting.key)
+		}
+	}
+
+	if config.Format == "" {
+		config.Format = "{{.Value}}"
+	}
+	format, err := template.New(config.Property).Parse(config.Format)
+	if err != nil {
+		return err
+	}
+
+	dp.config, dp.format = config, format
+	return nil
+}
+
+func (dp *dbusPropertyProvider) render() (string, error) {
+	if dp.format == nil {
+		return "", errors.New("the dbus-property block has no property to show, set one in [dbus-property]")
+	}
+
+	value, err := readDBusProperty(dp.config)
+	if err != nil {
+		logger.Println("Could not read", dp.config.Interface+"."+dp.config.Property, err)
+		return "", nil
+	}
+
+	var text strings.Builder
+	err = dp.format.Execute(&text, struct{ Value interface{} }{value})
+	if err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+func (dp *dbusPropertyProvider) name() string {
+	return "dbus-property"
+}
+
+func (dp *dbusPropertyProvider) respondToClick(event clickEvent) {}
@@ -0,0 +1,81 @@
+//go:build dbus
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Blocks that only work over DBus, like dbus-property, are only offered when this is set
+const dbusSupported = true
+
+func connectToBus(bus string) (*dbus.Conn, error) {
+	switch bus {
+	case "session", "":
+		return dbus.SessionBus()
+	case "system":
+		return dbus.SystemBus()
+	}
+	return nil, fmt.Errorf("unknown bus %q, expected session or system", bus)
+}
+
+func readDBusProperty(config dbusPropertyConfig) (interface{}, error) {
+	connection, err := connectToBus(config.Bus)
+	if err != nil {
+		return nil, err
+	}
+
+	object := connection.Object(config.Service, dbus.ObjectPath(config.Path))
+	value, err := object.GetProperty(config.Interface + "." + config.Property)
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Value(), nil
+}
+
+// Sends whenever the property's interface at its path reports a change. The new value is
+// read again afterwards rather than taken from the signal, since properties can be reported
+// as invalidated without a value.
+func watchDBusProperty(config dbusPropertyConfig) (<-chan struct{}, error) {
+	connection, err := connectToBus(config.Bus)
+	if err != nil {
+		return nil, err
+	}
+
+	path := dbus.ObjectPath(config.Path)
+	err = connection.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, config.Interface),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	connection.Signal(signals)
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		// The connection is shared, so signals meant for other blocks arrive here too
+		for signal := range signals {
+			if signal.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" || signal.Path != path {
+				continue
+			}
+			if len(signal.Body) == 0 || signal.Body[0] != config.Interface {
+				continue
+			}
+
+			select {
+			case changed <- struct{}{}:
+			default: // Already pending
+			}
+		}
+	}()
+
+	return changed, nil
+}
@@ -0,0 +1,15 @@
+//go:build !dbus
+
+package main
+
+import "errors"
+
+const dbusSupported = false
+
+func readDBusProperty(config dbusPropertyConfig) (interface{}, error) {
+	return nil, errors.New("built without DBus support, rebuild with -tags dbus")
+}
+
+func watchDBusProperty(config dbusPropertyConfig) (<-chan struct{}, error) {
+	return nil, errors.New("built without DBus support, rebuild with -tags dbus")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDBusPropertyConfigure(t *testing.T) {
+	upower := dbusPropertyConfig{
+		Bus:       "system",
+		Service:   "org.freedesktop.UPower",
+		Path:      "/org/freedesktop/UPower/devices/DisplayDevice",
+		Interface: "org.freedesktop.UPower.Device",
+		Property:  "Percentage",
+		Format:    `{{printf "%.0f" .Value}}%`,
+	}
+
+	tests := []struct {
+		name      string
+		change    func(config *dbusPropertyConfig)
+		wantError string
+	}{
+		{"complete", func(config *dbusPropertyConfig) {}, ""},
+		{"session bus by default", func(config *dbusPropertyConfig) { config.Bus = "" }, ""},
+		{"default format", func(config *dbusPropertyConfig) { config.Format = "" }, ""},
+		{"unknown bus", func(config *dbusPropertyConfig) { config.Bus = "user" }, `unknown bus "user"`},
+		{"no service", func(config *dbusPropertyConfig) { config.Service = "" }, "service is missing"},
+		{"no property", func(config *dbusPropertyConfig) { config.Property = "" }, "property is missing"},
+		{"bad format", func(config *dbusPropertyConfig) { config.Format = "{{.Value" }, "unclosed action"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := upower
+			test.change(&config)
+
+			provider := newDBusPropertyProvider()
+			err := provider.configure(config)
+			if test.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantError) {
+					t.Errorf("Got error %v, want %s", err, test.wantError)
+				}
+				if provider.format != nil {
+					t.Error("A bad config was kept")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var text strings.Builder
+			if err := provider.format.Execute(&text, struct{ Value interface{} }{42.4}); err != nil {
+				t.Fatal(err)
+			}
+			want := "42%"
+			if config.Format == "" {
+				want = "42.4"
+			}
+			if text.String() != want {
+				t.Errorf("Formatted as %q, want %q", text.String(), want)
+			}
+		})
+	}
+}
+
+func TestDBusPropertyUnconfigured(t *testing.T) {
+	provider := newDBusPropertyProvider()
+	if err := (DBusPropertyConfig{}).apply(provider); err != nil {
+		t.Fatal("An empty section is an error", err)
+	}
+	if _, err := provider.render(); err == nil || !strings.Contains(err.Error(), "[dbus-property]") {
+		t.Errorf("Got error %v, want one saying where to set the property", err)
+	}
+}
+
+func TestDBusPropertyConfigFromFile(t *testing.T) {
+	setupConfigFiles(t, "", `
+[dbus-property]
+bus = "system"
+service = "org.freedesktop.UPower"
+path = "/org/freedesktop/UPower/devices/DisplayDevice"
+interface = "org.freedesktop.UPower.Device"
+property = "Percentage"
+underline = 2
+`, "")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := newDBusPropertyProvider()
+	blockConfig, err := loadBlockConfig(config, map[string]blockProvider{"dbus-property": provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.checkUnknown(); err != nil {
+		t.Fatal(err)
+	}
+	if err := blockConfig.DBusProperty.apply(provider); err != nil {
+		t.Fatal(err)
+	}
+
+	if provider.config.Property != "Percentage" || provider.config.Bus != "system" {
+		t.Errorf("Got %+v", provider.config)
+	}
+	if blockConfig.Blocks["dbus-property"].Underline != 2 {
+		t.Error("The block's style wasn't read")
+	}
+}
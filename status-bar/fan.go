@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Shows the speed of a fan from lm-sensors, from the same sensors poll as the temperatures
+type fanProvider struct {
+	rpm   int
+	valid bool
+
+	sensors *sensorsSource
+
+	chip  string // Pattern for the sensors chip name, e.g. "thinkpad-*". Any chip if empty.
+	label string // e.g. "fan1"
+
+	hideWhenStopped bool
+}
+
+func (fan *fanProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if _, err := exec.LookPath("sensors"); err != nil {
+		logger.Println("sensors not found, can't read", fan.label)
+		return
+	}
+
+	if fan.sensors == nil {
+		fan.sensors = &sensorsSource{}
+	}
+
+	chip := fan.chip
+	if chip == "" {
+		chip = "*"
+	}
+
+	for range fan.sensors.subscribe() {
+		readings, err := fan.sensors.latest()
+
+		rpm, valid := 0.0, false
+		if err == nil {
+			rpm, valid = readings.find(chip, fan.label)
+		}
+
+		if int(rpm) != fan.rpm || valid != fan.valid {
+			fan.rpm, fan.valid = int(rpm), valid
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+}
+
+func (fan *fanProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when there is no such fan
+	if !fan.valid || (fan.hideWhenStopped && fan.rpm == 0) {
+		return block
+	}
+
+	block.Instance = fan.label
+	block.FullText = fmt.Sprintf("󰈐 %s", formatNumber(fan.rpm))
+	block.ShortText = "󰈐"
+
+	return block
+}
+
+func (fan *fanProvider) blockInstance() string {
+	return fan.label
+}
+
+func (fan *fanProvider) name() string {
+	return ""
+}
+
+func (fan *fanProvider) respondToClick(event clickEvent) {}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// A reader that takes longer than this to accept a status is dropped so that the bar keeps going
+const fifoWriteTimeout = 1 * time.Second
+
+// Writes the bar to a named pipe instead of stdout, for when the bar runs on its own and swaybar
+// reads it with e.g. status_command cat $XDG_RUNTIME_DIR/status-bar.fifo. A reader can leave and
+// come back at any time. The first write is the protocol header, which every reader gets when it
+// connects, followed by the latest status, so it doesn't have to wait for the next update.
+//
+// A pipe hands each byte to only one reader, so two readers of the same pipe split the statuses
+// between them and neither sees a valid stream. Give every bar its own pipe, see -output-fifo.
+// Clicks only come from stdin, so they don't reach the bar this way.
+type fifoOutput struct {
+	path string
+
+	lock     sync.Mutex
+	preamble []byte
+	latest   []byte
+	file     *os.File // Nil while there is no reader
+}
+
+// Creates the pipe if it doesn't exist yet
+func newFifoOutput(path string) (*fifoOutput, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = syscall.Mkfifo(path, 0600)
+	} else if err == nil && info.Mode()&fs.ModeNamedPipe == 0 {
+		err = fmt.Errorf("%s exists and is not a named pipe", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fifoOutput{
+		path: path,
+	}, nil
+}
+
+// Never fails, a status that can't be written is kept for the next reader
+func (fifo *fifoOutput) Write(p []byte) (int, error) {
+	fifo.lock.Lock()
+	defer fifo.lock.Unlock()
+
+	if fifo.preamble == nil {
+		fifo.preamble = append([]byte{}, p...)
+		go fifo.acceptReaders()
+		return len(p), nil
+	}
+
+	fifo.latest = append(fifo.latest[:0], p...)
+	if fifo.file != nil {
+		fifo.file.SetWriteDeadline(time.Now().Add(fifoWriteTimeout))
+		_, err := fifo.file.Write(p)
+		if err != nil {
+			logger.Println("Dropping reader of", fifo.path, err)
+			fifo.file.Close()
+			fifo.file = nil
+		}
+	}
+
+	return len(p), nil
+}
+
+func (fifo *fifoOutput) acceptReaders() {
+	for {
+		// Blocks until a reader opens the pipe
+		file, err := os.OpenFile(fifo.path, os.O_WRONLY, 0)
+		if err != nil {
+			logger.Println("Could not open", fifo.path, err)
+			return
+		}
+
+		fifo.lock.Lock()
+		file.SetWriteDeadline(time.Now().Add(fifoWriteTimeout))
+		_, err = file.Write(append(append([]byte{}, fifo.preamble...), fifo.latest...))
+		if err != nil {
+			file.Close()
+		} else {
+			fifo.file = file
+		}
+		fifo.lock.Unlock()
+
+		if err != nil {
+			logger.Println("Could not start a reader of", fifo.path, err)
+			continue
+		}
+
+		logger.Println("Reader connected to", fifo.path)
+		fifo.awaitReadersLeaving(file)
+	}
+}
+
+// Returns once every reader has closed the pipe or Write has dropped them. Until the pipe is
+// opened again, a new reader would share it and start in the middle of the stream.
+func (fifo *fifoOutput) awaitReadersLeaving(file *os.File) {
+	// Polled on a copy so that Write can close the file without waiting for the poll
+	var pollFd int
+	var err error
+	raw, _ := file.SyscallConn()
+	raw.Control(func(fd uintptr) {
+		pollFd, err = unix.Dup(int(fd))
+	})
+	if err != nil {
+		logger.Println("Could not watch the readers of", fifo.path, err)
+		fifo.lock.Lock()
+		if fifo.file == file {
+			fifo.file.Close()
+			fifo.file = nil
+		}
+		fifo.lock.Unlock()
+		return
+	}
+	defer unix.Close(pollFd)
+
+	for {
+		// The write end of a pipe reports POLLERR once it has no readers left
+		fds := []unix.PollFd{{Fd: int32(pollFd)}}
+		ready, _ := unix.Poll(fds, int(fifoWriteTimeout/time.Millisecond))
+
+		fifo.lock.Lock()
+		if ready > 0 && fifo.file == file {
+			fifo.file.Close()
+			fifo.file = nil
+		}
+		dropped := fifo.file != file
+		fifo.lock.Unlock()
+
+		if dropped {
+			logger.Println("Reader left", fifo.path)
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+type fullscreenProvider struct {
+	fullscreen bool
+}
+
+// Returns whether the focused node was found under node and if so, whether it or any of its
+// ancestors is fullscreen. Focusing a split inside a fullscreen container keeps it fullscreen.
+func findFocusedFullscreen(node *swayNode, ancestorFullscreen bool) (found bool, fullscreen bool) {
+	fullscreen = ancestorFullscreen || node.FullscreenMode != 0
+	if node.Focused {
+		return true, fullscreen
+	}
+
+	for _, children := range [][]swayNode{node.Nodes, node.FloatingNodes} {
+		for i := range children {
+			if found, childFullscreen := findFocusedFullscreen(&children[i], fullscreen); found {
+				return true, childFullscreen
+			}
+		}
+	}
+
+	return false, false
+}
+
+func isFocusedFullscreen() (bool, error) {
+	tree, err := getSwayTree()
+	if err != nil {
+		return false, err
+	}
+
+	_, fullscreen := findFocusedFullscreen(&tree, false)
+	return fullscreen, nil
+}
+
+func (fp *fullscreenProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	update := func() {
+		fullscreen, err := isFocusedFullscreen()
+		if err != nil {
+			logger.Println("Could not read fullscreen state", err)
+			return
+		}
+
+		if fullscreen != fp.fullscreen {
+			fp.fullscreen = fullscreen
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+
+	update()
+
+	// Switching to a workspace with a fullscreen window doesn't send a window event. Unplugging
+	// the focused output moves focus to another one, which can come with only an output event.
+	subscription, err := swaySubscribe("window", "workspace", "output")
+	if err != nil {
+		logger.Println("Could not subscribe to window events", err)
+		return
+	}
+	defer subscription.Close()
+
+	for {
+		_, _, err := subscription.ReadEvent()
+		if err != nil {
+			logger.Println("Window event subscription closed", err)
+			return
+		}
+		update()
+	}
+}
+
+func (fp *fullscreenProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when nothing is fullscreen
+	if fp.fullscreen {
+		block.FullText = ""
+	}
+
+	return block
+}
+
+func (fp *fullscreenProvider) name() string {
+	return ""
+}
+
+func (fp *fullscreenProvider) respondToClick(event clickEvent) {}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Replaces single glyphs at render time, for fonts that lack some of them. There is no way to
+// tell at runtime whether the font has a glyph, so the replacements are configured with -glyph,
+// once per glyph, e.g.
+//
+//	-glyph U+F0E7=AC -glyph 󰖩=wifi
+//
+// The replacement is inserted as is, so it must be escaped for blocks that use pango markup.
+type glyphOverrides map[rune]string
+
+var glyphReplacements = glyphOverrides{}
+
+// Drops every Nerd Font glyph that has no override, for fonts without any of them
+var asciiGlyphs bool
+
+func (overrides glyphOverrides) String() string {
+	glyphs := make([]string, 0, len(overrides))
+	for glyph, replacement := range overrides {
+		glyphs = append(glyphs, fmt.Sprintf("U+%04X=%s", glyph, replacement))
+	}
+	sort.Strings(glyphs)
+	return strings.Join(glyphs, " ")
+}
+
+// Takes either the glyph itself or its code point, e.g. U+F0E7 or f0e7
+func (overrides glyphOverrides) Set(value string) error {
+	glyphText, replacement, found := strings.Cut(value, "=")
+	if !found {
+		return fmt.Errorf("expected <glyph>=<replacement>, got %q", value)
+	}
+
+	glyph, size := utf8.DecodeRuneInString(glyphText)
+	if size != len(glyphText) || glyph == utf8.RuneError {
+		codePoint := strings.TrimPrefix(strings.TrimPrefix(glyphText, "U+"), "u+")
+		parsed, err := strconv.ParseUint(codePoint, 16, 32)
+		if err != nil {
+			return fmt.Errorf("%q is neither a glyph nor a code point like U+F0E7", glyphText)
+		}
+		glyph = rune(parsed)
+	}
+
+	overrides[glyph] = replacement
+	return nil
+}
+
+// Nerd Font glyphs are in the private use areas
+func isNerdFontGlyph(r rune) bool {
+	return (r >= 0xE000 && r <= 0xF8FF) || (r >= 0xF0000 && r <= 0xFFFFD)
+}
+
+func replaceGlyphsInText(text string) string {
+	var result strings.Builder
+	dropped := false
+	for _, r := range text {
+		if replacement, exists := glyphReplacements[r]; exists {
+			result.WriteString(replacement)
+		} else if asciiGlyphs && isNerdFontGlyph(r) {
+			dropped = true
+		} else {
+			result.WriteRune(r)
+		}
+	}
+
+	// The space that separated the glyph from the value
+	if dropped {
+		return strings.TrimSpace(result.String())
+	}
+	return result.String()
+}
+
+func replaceGlyphs(fullBlockValues []fullSwaybarMessageBodyBlock) []fullSwaybarMessageBodyBlock {
+	if len(glyphReplacements) == 0 && !asciiGlyphs {
+		return fullBlockValues
+	}
+
+	result := make([]fullSwaybarMessageBodyBlock, len(fullBlockValues))
+	for i, block := range fullBlockValues {
+		fullText := replaceGlyphsInText(block.FullText)
+
+		// Blocks that are only a glyph would disappear
+		if fullText == "" && block.FullText != "" {
+			fullText = block.Name
+		}
+
+		block.FullText = fullText
+		block.ShortText = replaceGlyphsInText(block.ShortText)
+		result[i] = block
+	}
+	return result
+}
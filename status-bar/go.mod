@@ -3,3 +3,12 @@ module status-bar
 go 1.20
 
 require golang.org/x/sys v0.13.0
+
+require github.com/godbus/dbus/v5 v5.1.0
+
+require github.com/BurntSushi/toml v1.6.0
+
+require swayipc v0.0.0
+
+// Shared with the other tools in the repo
+replace swayipc => ../swayipc
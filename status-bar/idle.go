@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Providers told about idle and active with -idle and -active implement this. It must not block.
+type idleAwareProvider interface {
+	setIdle(idle bool)
+}
+
+// Counts down to swayidle locking the screen. Neither sway nor swayidle can be asked how long
+// the session has been idle, so swayidle tells the bar instead, with an extra timeout that runs
+// before the lock one, e.g. for a 300 second lock and lockIn of one minute:
+//
+//	swayidle -w \
+//	    timeout 240 'status-bar -idle' resume 'status-bar -active' \
+//	    timeout 300 'swaylock -f'
+type idleProvider struct {
+	remaining time.Duration
+	idle      bool
+
+	lockIn       time.Duration // Time between the -idle timeout and the lock timeout
+	urgentWithin time.Duration // Urgent once the lock is this close
+	events       chan bool
+}
+
+func (ip *idleProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	var deadline time.Time
+
+	// Nil while active
+	var ticks <-chan time.Time
+	var ticker *time.Ticker
+
+	for {
+		select {
+		case idle := <-ip.events:
+			if idle == (ticker != nil) {
+				continue
+			}
+
+			if idle {
+				deadline = time.Now().Add(ip.lockIn)
+				ticker = time.NewTicker(1 * time.Second)
+				ticks = ticker.C
+			} else {
+				ticker.Stop()
+				ticker, ticks = nil, nil
+			}
+		case <-ticks:
+		}
+
+		remaining := time.Duration(0)
+		if ticker != nil {
+			remaining = time.Until(deadline).Round(time.Second)
+		}
+
+		// Locked by now, the count stops until the session is active again
+		idle := ticker != nil && remaining > 0
+		if !idle && ticker != nil {
+			ticker.Stop()
+			ticker, ticks = nil, nil
+		}
+
+		if idle != ip.idle || remaining != ip.remaining {
+			ip.idle, ip.remaining = idle, remaining
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+}
+
+func (ip *idleProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden while active
+	if !ip.idle {
+		return block
+	}
+
+	seconds := int(ip.remaining / time.Second)
+	block.FullText = fmt.Sprintf("󰌾 %d:%02d", seconds/60, seconds%60)
+	block.ShortText = "󰌾"
+
+	if ip.remaining <= ip.urgentWithin {
+		urgent := true
+		block.Urgent = &urgent
+	}
+
+	return block
+}
+
+func (ip *idleProvider) name() string {
+	return "idle"
+}
+
+func (ip *idleProvider) respondToClick(event clickEvent) {}
+
+func (ip *idleProvider) setIdle(idle bool) {
+	select {
+	case ip.events <- idle:
+	default:
+		logger.Println("Dropping idle state change, too many at once")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"swayipc"
+)
+
+type swayInput struct {
+	Identifier           string   `json:"identifier"`
+	Type                 string   `json:"type"`
+	XkbLayoutNames       []string `json:"xkb_layout_names"`
+	XkbActiveLayoutIndex int      `json:"xkb_active_layout_index"`
+}
+
+// Layouts are the same on every keyboard unless the sway config sets them per device,
+// in which case the first keyboard is the one shown
+func getKeyboardInput() (swayInput, bool, error) {
+	jsonBytes, err := swayMsgCommand(swayipc.IPC_GET_INPUTS, "")
+	if err != nil {
+		return swayInput{}, false, err
+	}
+
+	var inputs []swayInput
+	err = json.Unmarshal(jsonBytes, &inputs)
+	if err != nil {
+		return swayInput{}, false, err
+	}
+
+	for _, input := range inputs {
+		if input.Type == "keyboard" && len(input.XkbLayoutNames) > 0 {
+			return input, true, nil
+		}
+	}
+	return swayInput{}, false, nil
+}
+
+// sway only reports the long xkb names, e.g. "English (US)"
+var layoutShortNames = map[string]string{
+	"English":    "en",
+	"German":     "de",
+	"French":     "fr",
+	"Spanish":    "es",
+	"Italian":    "it",
+	"Portuguese": "pt",
+	"Russian":    "ru",
+	"Ukrainian":  "ua",
+	"Greek":      "gr",
+	"Bulgarian":  "bg",
+	"Romanian":   "ro",
+	"Polish":     "pl",
+	"Japanese":   "jp",
+}
+
+// "English (US)" becomes "us", "German" becomes "de". Unknown names are cut to two letters.
+func shortLayoutName(name string) string {
+	if open := strings.LastIndex(name, "("); open >= 0 {
+		if variant, _, found := strings.Cut(name[open+1:], ")"); found && len(variant) <= 3 {
+			return strings.ToLower(variant)
+		}
+	}
+
+	language, _, _ := strings.Cut(name, " ")
+	if short, exists := layoutShortNames[language]; exists {
+		return short
+	}
+
+	runes := []rune(strings.ToLower(language))
+	if len(runes) > 2 {
+		runes = runes[:2]
+	}
+	return string(runes)
+}
+
+type keyboardLayoutProvider struct {
+	layouts     []string
+	activeIndex int
+}
+
+func (kb *keyboardLayoutProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	update := func() {
+		input, found, err := getKeyboardInput()
+		if err != nil {
+			logger.Println("Could not read keyboard layouts", err)
+			return
+		}
+		if !found {
+			input = swayInput{}
+		}
+
+		if input.XkbActiveLayoutIndex != kb.activeIndex || strings.Join(input.XkbLayoutNames, ",") != strings.Join(kb.layouts, ",") {
+			kb.layouts, kb.activeIndex = input.XkbLayoutNames, input.XkbActiveLayoutIndex
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+
+	update()
+
+	subscription, err := swaySubscribe("input")
+	if err != nil {
+		logger.Println("Could not subscribe to input events", err)
+		return
+	}
+	defer subscription.Close()
+
+	for {
+		_, _, err := subscription.ReadEvent()
+		if err != nil {
+			logger.Println("Input event subscription closed", err)
+			return
+		}
+		update()
+	}
+}
+
+func (kb *keyboardLayoutProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	if kb.activeIndex >= 0 && kb.activeIndex < len(kb.layouts) {
+		block.ShortText = shortLayoutName(kb.layouts[kb.activeIndex])
+		block.FullText = " " + block.ShortText
+	}
+
+	return block
+}
+
+func (kb *keyboardLayoutProvider) name() string {
+	return "keyboard layout"
+}
+
+// Cycles through the layouts in the order they are configured in sway
+func (kb *keyboardLayoutProvider) respondToClick(event clickEvent) {
+	if event.Button != 1 {
+		return
+	}
+
+	input, found, err := getKeyboardInput()
+	if err != nil {
+		logger.Println("Could not read keyboard layouts", err)
+		return
+	}
+	if !found || len(input.XkbLayoutNames) < 2 {
+		return // Nothing to switch to
+	}
+
+	next := (input.XkbActiveLayoutIndex + 1) % len(input.XkbLayoutNames)
+	_, err = swayMsgCommand(swayipc.IPC_COMMAND, fmt.Sprintf("input type:keyboard xkb_switch_layout %d", next))
+	if err != nil {
+		logger.Println("Could not switch keyboard layout", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+/*
+   Swaybar has no way to align blocks. The status line is always drawn against the right edge
+   of the bar (next to the tray), with blocks in order from left to right. To fake alignment,
+   blocks are put into left, center and right groups with empty spacer blocks between them
+   that push the groups apart.
+
+   Limitations:
+   - The spacers have a fixed width in pixels since the bar's width and font aren't known here,
+     so they have to be tuned by hand for each screen and the groups only line up while the
+     other blocks stay roughly the same width.
+   - The status line never extends under the workspace buttons, so "left" means the left end
+     of the status line, not of the bar.
+   - When there isn't enough room swaybar cuts off blocks from the left, spacers included.
+*/
+
+type blockLayout struct {
+	left   []blockProvider
+	center []blockProvider
+	right  []blockProvider
+
+	leftSpacerWidth  int // Pixels between the left and center groups
+	rightSpacerWidth int // Pixels between the center and right groups
+}
+
+// An empty block that takes up space
+type spacerProvider struct {
+	width int
+}
+
+func (spacerProvider) monitor(changeChan chan<- blockChangedMessage, index int) {}
+
+func (spacer spacerProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Blocks without any text are skipped, so it needs at least a space
+	block.FullText = " "
+	width := spacer.width
+	block.MinWidth = &width
+	separator := false
+	block.Separator = &separator
+	separatorWidth := 0
+	block.SeparatorBlockWidth = &separatorWidth
+
+	return block
+}
+
+func (spacerProvider) name() string {
+	return ""
+}
+
+func (spacerProvider) respondToClick(event clickEvent) {}
+
+// Flattens the groups into the order that they are sent to swaybar, with spacers between non-empty groups
+func (layout blockLayout) providers() []blockProvider {
+	result := []blockProvider{}
+	result = append(result, layout.left...)
+
+	if len(layout.left) > 0 && (len(layout.center) > 0 || len(layout.right) > 0) && layout.leftSpacerWidth > 0 {
+		result = append(result, spacerProvider{width: layout.leftSpacerWidth})
+	}
+	result = append(result, layout.center...)
+
+	if len(layout.center) > 0 && len(layout.right) > 0 && layout.rightSpacerWidth > 0 {
+		result = append(result, spacerProvider{width: layout.rightSpacerWidth})
+	}
+	result = append(result, layout.right...)
+
+	return result
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -18,6 +21,12 @@ import (
 	// "golang.org/x/sys/unix"
 )
 
+// The version of swaybar-protocol(7) that is advertised in the header. Version 1 is the only
+// one swaybar has so far. Everything in fullSwaybarMessageBodyBlock and clickEvent is part of
+// version 1, so if a later version adds or renames fields, bump this and make sure that no
+// field is sent to a swaybar that advertised an older version than the one it was added in.
+const swaybarProtocolVersion = 1
+
 type swaybarMessageHeader struct {
 	Version     int       `json:"version"`
 	ClickEvents bool      `json:"click_events"`
@@ -25,12 +34,18 @@ type swaybarMessageHeader struct {
 	StopSignal  os.Signal `json:"stop_signal"`
 }
 
-func sendHeader(header swaybarMessageHeader) {
+// Written as one line followed by the "[" that opens the endless array of statuses, in a single
+// write so that a fifoOutput can replay it to every reader.
+func sendHeader(out io.Writer, header swaybarMessageHeader) {
+	if header.Version < 1 || header.Version > swaybarProtocolVersion {
+		logger.Panicf("Unsupported swaybar protocol version %d, only versions up to %d are implemented", header.Version, swaybarProtocolVersion)
+	}
+
 	bytes, err := json.Marshal(header)
 	if err != nil {
 		logger.Panic(err)
 	}
-	fmt.Println(string(bytes))
+	fmt.Fprintf(out, "%s\n[", bytes)
 }
 
 /*
@@ -127,86 +142,332 @@ type blockChangedMessage struct {
 	index int
 }
 
+// Blocks are drawn as a glyph followed by their value. They also set ShortText to the glyph
+// alone, or to the value alone when the glyph means nothing by itself (e.g. the clock), and leave
+// it empty when FullText is already that short. Swaybar renders every block with its short_text
+// when the full texts don't fit on the output, see -compact-when-narrow.
 type blockProvider interface {
 	monitor(changeChan chan<- blockChangedMessage, index int)
-	createBlock() fullSwaybarMessageBodyBlock
-	name() string // if this is non-empty, then it will receive click events
+	createBlock() fullSwaybarMessageBodyBlock // Renders state that monitor has already fetched. Must not block or run commands.
+	name() string                             // if this is non-empty, then it will receive click events
 	respondToClick(event clickEvent)
 }
 
+// Providers that can be shown more than once, e.g. one temperature block per sensor, tell their
+// blocks apart with an instance. Clicks are routed by name and instance together.
+type instancedProvider interface {
+	blockInstance() string
+}
+
+func providerInstance(provider blockProvider) string {
+	if instanced, ok := provider.(instancedProvider); ok {
+		return instanced.blockInstance()
+	}
+	return ""
+}
+
+// Providers whose render can fail implement this as well as createBlock, which is then only
+// used by code that doesn't check for errors. On error the block is drawn as errorBlock instead
+// and the rest of the bar is unaffected.
+type fallibleProvider interface {
+	createBlockOrError() (fullSwaybarMessageBodyBlock, error)
+}
+
+// Lets every provider be rendered the same way, whether it can fail or not
+func renderBlock(provider blockProvider) (fullSwaybarMessageBodyBlock, error) {
+	if fallible, ok := provider.(fallibleProvider); ok {
+		return fallible.createBlockOrError()
+	}
+	return provider.createBlock(), nil
+}
+
+const errorBlockColor = "#FF5555"
+
+// What a block shows when it couldn't be rendered. The name says which block it is, since the
+// glyph it would normally start with is missing.
+func errorBlock(provider blockProvider) fullSwaybarMessageBodyBlock {
+	text := ""
+	if provider.name() != "" {
+		text += " " + provider.name()
+	}
+
+	block := fullSwaybarMessageBodyBlock{
+		FullText:  text,
+		ShortText: "",
+		Color:     errorBlockColor,
+	}
+
+	return block
+}
+
+// How often a block may be redrawn unless its config says otherwise, see BlockConfig
+const defaultMinUpdateInterval = 100 * time.Millisecond
+
+func minUpdateInterval(index int) time.Duration {
+	if interval := blockSettingsAt(index).MinUpdateInterval; interval > 0 {
+		return interval
+	}
+	return defaultMinUpdateInterval
+}
+
 // Can't use SIGRTMIN for some reason
 const VOLUME_CHANGED_SIGNAL = syscall.SIGUSR1
 
-type volumeProvider struct {
-	leftMuted   bool
+// How a provider that supports both finds out about changes
+type updateMode int
+
+const (
+	// Reacts to change notifications as they happen. Updates are instant, but
+	// a subscription (e.g. a long running `pactl subscribe`) is kept open.
+	updateModeEvent updateMode = iota
+	// Re-reads the value every poll interval. Nothing is kept running in the
+	// background, but changes can take up to a full interval to show up.
+	updateModePoll
+)
+
+const defaultPollInterval = 5 * time.Second
+
+func parseUpdateMode(value string) (updateMode, error) {
+	switch value {
+	case "event":
+		return updateModeEvent, nil
+	case "poll":
+		return updateModePoll, nil
+	}
+	return 0, fmt.Errorf("unknown update mode %q, options are event, which shows changes at once but keeps a subscription "+
+		"running, and poll, which runs nothing in between but can take a whole poll interval to show a change", value)
+}
+
+type volumeState struct {
 	leftVolume  int
-	rightMuted  bool
+	leftMuted   bool
 	rightVolume int
+	rightMuted  bool
+}
+
+type volumeProvider struct {
+	commandProvider[volumeState]
+
+	mode         updateMode
+	pollInterval time.Duration // Only used with updateModePoll
+
+	backends    []string // Names from volumeBackends in the order they are tried, defaults to defaultVolumeBackends
+	backendName string
+	backend     volumeBackend
+	failures    int // Reads in a row that failed
+
+	// Read the volume from PulseAudio over DBus instead of the backend. Needs a build with -tags dbus.
+	useDBus bool
+
+	muteDisplay muteDisplay // Defaults to muteGlyph
+	step        int         // Percent per scroll step, defaults to volumeStep
+	mixer       string      // The amixer control to use, defaults to Master
 }
 
-func (vol *volumeProvider) updateVolume() {
-	volAndMuted := func(line string) (int, bool) {
+// How a muted channel is shown
+type muteDisplay string
+
+const (
+	muteGlyph         muteDisplay = "glyph"         // Only the muted speaker glyph
+	muteWord          muteDisplay = "word"          // The muted speaker glyph and "mute"
+	muteRed           muteDisplay = "red"           // The volume in mutedVolumeColor
+	muteStrikethrough muteDisplay = "strikethrough" // The volume struck through
+)
+
+const mutedVolumeColor = "#FF5555"
+
+func parseMuteDisplay(value string) (muteDisplay, error) {
+	switch display := muteDisplay(value); display {
+	case muteGlyph, muteWord, muteRed, muteStrikethrough:
+		return display, nil
+	}
+	return "", fmt.Errorf("unknown mute display %q, options are glyph, word, red and strikethrough", value)
+}
+
+// How much scrolling on the block changes the volume by unless volumeProvider.step is set, and by
+// how much while holding shift
+const volumeStep = 5
+const fineVolumeStep = 1
+
+// The last two lines of amixer get Master are the channels, e.g.
+//
+//	Front Left: Playback 42 [65%] [-16.50dB] [on]
+//	Front Right: Playback 42 [65%] [-16.50dB] [on]
+func parseAmixerVolume(output []byte) (volumeState, error) {
+	volAndMuted := func(line string) (int, bool, error) {
 		numIndex := strings.Index(line, "[") + 1
 		percentIndex := strings.Index(line, "%")
+		if numIndex <= 0 || percentIndex < numIndex || percentIndex+2 > len(line) {
+			return 0, false, fmt.Errorf("no volume in %q", line)
+		}
 		volume, err := strconv.Atoi(line[numIndex:percentIndex])
 		if err != nil {
-			logger.Panic(err)
+			return 0, false, err
 		}
 
 		lineAfterNum := line[percentIndex+2:]
-		mutedIndex := strings.Index(lineAfterNum, "[") + 1
-		closeBracketIndex := strings.Index(lineAfterNum, "]")
-		isMuted := lineAfterNum[mutedIndex:closeBracketIndex] == "off"
+		mutedIndex := strings.LastIndex(lineAfterNum, "[") + 1
+		closeBracketIndex := strings.LastIndex(lineAfterNum, "]")
+		isMuted := mutedIndex > 0 && closeBracketIndex >= mutedIndex && lineAfterNum[mutedIndex:closeBracketIndex] == "off"
 
-		return volume, isMuted
+		return volume, isMuted, nil
 	}
 
-	output, err := exec.Command("amixer", "get", "Master").Output()
+	var state volumeState
+	lines := strings.Split(string(output), "\n")
+	if len(lines) < 3 {
+		return state, fmt.Errorf("unexpected amixer output %q", output)
+	}
+	lines = lines[len(lines)-3:]
+
+	var err error
+	state.leftVolume, state.leftMuted, err = volAndMuted(lines[0])
 	if err != nil {
-		logger.Panic(err)
+		return state, err
+	}
+	state.rightVolume, state.rightMuted, err = volAndMuted(lines[1])
+	return state, err
+}
+
+func (vol *volumeProvider) readVolume() (volumeState, error) {
+	if vol.useDBus {
+		leftVolume, leftMuted, rightVolume, rightMuted, err := readVolumeDBus()
+		if err == nil {
+			return volumeState{leftVolume, leftMuted, rightVolume, rightMuted}, nil
+		}
+
+		logger.Println("Could not read volume over DBus, falling back to", vol.backendName, err)
+		vol.useDBus = false
 	}
 
-	lines := strings.Split(string(output), "\n")
-	lines = lines[len(lines)-3:]
+	if vol.backend == nil {
+		return volumeState{}, errNoVolumeBackend
+	}
+
+	state, err := vol.backend.read()
+	if err != nil {
+		vol.failures++
+		if vol.failures >= volumeBackendMaxFailures {
+			logger.Println(vol.backendName, "failed", vol.failures, "times in a row, looking for another volume backend")
+			vol.failures = 0
+			vol.selectBackend()
+		}
+		return state, err
+	}
 
-	vol.leftVolume, vol.leftMuted = volAndMuted(lines[0])
-	vol.rightVolume, vol.rightMuted = volAndMuted(lines[1])
+	vol.failures = 0
+	return state, nil
 }
 
-func (vol *volumeProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, VOLUME_CHANGED_SIGNAL)
-	vol.updateVolume()
+// Keeps the current backend if none of them work
+func (vol *volumeProvider) selectBackend() {
+	names := vol.backends
+	if len(names) == 0 {
+		names = defaultVolumeBackends
+	}
 
-	for {
-		sig := <-signals
-		if sig == VOLUME_CHANGED_SIGNAL {
-			leftVol, leftMute, rightVol, rightMute := vol.leftVolume, vol.leftMuted, vol.rightVolume, vol.rightMuted
-			vol.updateVolume()
+	name, backend, err := probeVolumeBackends(names, vol.mixer)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
 
-			if vol.leftVolume != leftVol || vol.leftMuted != leftMute || vol.rightVolume != rightVol || vol.rightMuted != rightMute {
-				changeChan <- blockChangedMessage{
-					index: index,
+	logger.Println("Using", name, "for the volume")
+	vol.backendName, vol.backend = name, backend
+}
+
+// Sends on the returned channel whenever pulseaudio reports a change to a sink.
+// Returns nil if pactl can't be started, which leaves only VOLUME_CHANGED_SIGNAL.
+func subscribeSinkEvents() <-chan struct{} {
+	pactl := exec.Command("pactl", "subscribe")
+	stdout, err := pactl.StdoutPipe()
+	if err != nil {
+		logger.Println("Could not create pactl pipe", err)
+		return nil
+	}
+
+	err = pactl.Start()
+	if err != nil {
+		logger.Println("pactl subscribe unavailable, only listening for", VOLUME_CHANGED_SIGNAL, err)
+		return nil
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			// e.g. Event 'change' on sink #0
+			if strings.Contains(scanner.Text(), "on sink #") {
+				select {
+				case events <- struct{}{}:
+				default: // An update is already pending
 				}
 			}
 		}
+		pactl.Wait()
+		logger.Println("pactl subscribe exited")
+	}()
+
+	return events
+}
+
+func (vol *volumeProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	vol.selectBackend()
+	vol.fetch = vol.readVolume
+	vol.refreshSignal = VOLUME_CHANGED_SIGNAL
+
+	if vol.mode == updateModePoll {
+		vol.commandProvider.pollInterval = vol.pollInterval
+		if vol.pollInterval <= 0 {
+			vol.commandProvider.pollInterval = defaultPollInterval
+		}
+	} else if vol.backend != nil {
+		// Stays subscribed to the first backend if another one is picked later
+		vol.events = vol.backend.subscribe
 	}
+
+	vol.commandProvider.monitor(changeChan, index)
 }
 
+// From quiet to loud
+var volumeGlyphs = []string{"", ""}
+
 func (vol *volumeProvider) createBlock() fullSwaybarMessageBodyBlock {
-	getVolumeString := func(vol int, muted bool) string {
-		if muted {
+	getVolumeString := func(volume int, muted bool) string {
+		glyph := rampGlyph(float64(volume), volumeGlyphs)
+		if !muted {
+			return fmt.Sprintf("%s %d%%", glyph, volume)
+		}
+
+		switch vol.muteDisplay {
+		case muteWord:
 			return " mute"
+		case muteRed:
+			return fmt.Sprintf(`<span foreground="%s">%s %d%%</span>`, mutedVolumeColor, glyph, volume)
+		case muteStrikethrough:
+			return fmt.Sprintf("%s <s>%d%%</s>", glyph, volume)
+		default:
+			return ""
 		}
-		return fmt.Sprintf(" %d%%", vol)
 	}
 
 	var block fullSwaybarMessageBodyBlock
+	if vol.muteDisplay == muteRed || vol.muteDisplay == muteStrikethrough {
+		block.Markup = "pango"
+	}
 
-	if vol.leftMuted == vol.rightMuted || vol.leftVolume == vol.rightVolume {
-		block.FullText = getVolumeString(vol.leftVolume, vol.leftMuted)
+	state := vol.value
+	if state.leftMuted && state.rightMuted {
+		block.ShortText = ""
 	} else {
-		block.FullText = fmt.Sprintf("L:%s R:%s", getVolumeString(vol.leftVolume, vol.leftMuted), getVolumeString(vol.rightVolume, vol.rightMuted))
+		block.ShortText = rampGlyph(float64(state.leftVolume), volumeGlyphs)
+	}
+	if state.leftMuted == state.rightMuted || state.leftVolume == state.rightVolume {
+		block.FullText = getVolumeString(state.leftVolume, state.leftMuted)
+	} else {
+		block.FullText = joinSegments(segmentSeparator,
+			"L:"+getVolumeString(state.leftVolume, state.leftMuted),
+			"R:"+getVolumeString(state.rightVolume, state.rightMuted))
 	}
 
 	return block
@@ -216,52 +477,258 @@ func (vol *volumeProvider) name() string {
 	return "volume"
 }
 
+// Left click opens a mixer, middle or right click mutes and scrolling changes the volume, in
+// smaller steps with shift held
 func (vol *volumeProvider) respondToClick(event clickEvent) {
-	exec.Command("alacritty", "--class", "alsamixer", "-e", "alsamixer").Run()
+	if event.Button == 1 {
+		launchDetached("alacritty", "--class", "alsamixer", "-e", "alsamixer")
+		return
+	}
+
+	// The monitor may be switching backends or updating the value at the same time
+	vol.runOnMonitor(func() {
+		vol.changeVolume(event)
+	})
+}
+
+// Only called from the monitor goroutine, which reads the volume again afterwards since not
+// every backend reports its own changes
+func (vol *volumeProvider) changeVolume(event clickEvent) {
+	backend := vol.backend
+	if backend == nil {
+		return
+	}
+
+	var err error
+	switch event.Button {
+	case 2, 3:
+		err = backend.toggleMute()
+	case 4, 5:
+		step := vol.step
+		if step <= 0 {
+			step = volumeStep
+		}
+		if event.hasModifier("Shift") {
+			step = fineVolumeStep
+		}
+
+		percent := vol.value.leftVolume + step
+		if event.Button == 5 {
+			percent = vol.value.leftVolume - step
+		}
+		if percent < 0 {
+			percent = 0
+		} else if percent > 100 {
+			percent = 100
+		}
+		err = backend.set(percent)
+	default:
+		return
+	}
+
+	if err != nil {
+		logger.Println("Could not change volume with", vol.backendName, err)
+	}
 }
 
 // ---
 
+type weatherCondition int
+
+const (
+	conditionUnknown weatherCondition = iota
+	conditionClear
+	conditionPartlyCloudy
+	conditionCloudy
+	conditionFog
+	conditionRain
+	conditionSnow
+	conditionThunder
+)
+
+/*
+   Condition codes are the World Weather Online codes that wttr.in reports as weatherCode.
+   Icons are from the Weather Icons set included in Nerd Fonts.
+
+   ┌────────────────┬─────────────────────────────────────────────────────┬──────┬───────┐
+   │   CONDITION    │                    WEATHER CODES                    │ DAY  │ NIGHT │
+   ├────────────────┼─────────────────────────────────────────────────────┼──────┼───────┤
+   │ clear          │ 113                                                 │     │      │
+   │ partly cloudy  │ 116                                                 │     │      │
+   │ cloudy         │ 119 122                                             │     │      │
+   │ fog            │ 143 248 260                                         │     │      │
+   │ rain           │ 176 263 266 293 296 299 302 305 308 311 314 353 356 │     │      │
+   │                │ 359                                                 │      │       │
+   │ snow and sleet │ 179 182 185 227 230 281 284 317 320 323 326 329 332 │     │      │
+   │                │ 335 338 350 362 365 368 371 374 377 392 395         │      │       │
+   │ thunder        │ 200 386 389                                         │     │      │
+   └────────────────┴─────────────────────────────────────────────────────┴──────┴───────┘
+*/
+
+func weatherConditionFromCode(code string) weatherCondition {
+	switch code {
+	case "113":
+		return conditionClear
+	case "116":
+		return conditionPartlyCloudy
+	case "119", "122":
+		return conditionCloudy
+	case "143", "248", "260":
+		return conditionFog
+	case "176", "263", "266", "293", "296", "299", "302", "305", "308", "311", "314", "353", "356", "359":
+		return conditionRain
+	case "179", "182", "185", "227", "230", "281", "284", "317", "320", "323", "326", "329", "332",
+		"335", "338", "350", "362", "365", "368", "371", "374", "377", "392", "395":
+		return conditionSnow
+	case "200", "386", "389":
+		return conditionThunder
+	default:
+		return conditionUnknown
+	}
+}
+
+// Day and night icons for each condition
+var weatherIcons = map[weatherCondition][2]string{
+	conditionUnknown:      {"", ""},
+	conditionClear:        {"", ""},
+	conditionPartlyCloudy: {"", ""},
+	conditionCloudy:       {"", ""},
+	conditionFog:          {"", ""},
+	conditionRain:         {"", ""},
+	conditionSnow:         {"", ""},
+	conditionThunder:      {"", ""},
+}
+
+type wttrResponse struct {
+	CurrentCondition []struct {
+		TempC       string `json:"temp_C"`
+		WeatherCode string `json:"weatherCode"`
+		WeatherDesc []struct {
+			Value string `json:"value"`
+		} `json:"weatherDesc"`
+	} `json:"current_condition"`
+	Weather []struct {
+		Astronomy []struct {
+			Sunrise string `json:"sunrise"`
+			Sunset  string `json:"sunset"`
+		} `json:"astronomy"`
+	} `json:"weather"`
+}
+
+// sunrise and sunset are in wttr.in's format, e.g. "07:01 AM". Assumes day if they can't be parsed.
+func isDaytime(now time.Time, sunrise, sunset string) bool {
+	sunriseTime, err := time.Parse("03:04 PM", sunrise)
+	if err != nil {
+		return true
+	}
+	sunsetTime, err := time.Parse("03:04 PM", sunset)
+	if err != nil {
+		return true
+	}
+
+	minutes := now.Hour()*60 + now.Minute()
+	return minutes >= sunriseTime.Hour()*60+sunriseTime.Minute() && minutes < sunsetTime.Hour()*60+sunsetTime.Minute()
+}
+
 type weatherProvider struct {
-	weatherStatus string
+	refreshTrigger
+
+	weatherStatus string // Set instead of the fields below when the weather couldn't be fetched
+
+	condition   weatherCondition
+	description string
+	celsius     float64
+	isDay       bool
+
+	unit temperatureUnit
+
+	textOnly             bool // Show the description instead of an icon, for fonts without weather icons
+	maxDescriptionLength int  // Longer descriptions are cut off with "…", 0 for no limit
+
+	url            string        // Defaults to defaultWeatherURL. Must answer in wttr.in's j1 format.
+	updateInterval time.Duration // Defaults to an hour
+}
+
+const defaultWeatherURL = "https://wttr.in?format=j1"
+
+func (w *weatherProvider) updateFromResponse(responseBody []byte) {
+	var response wttrResponse
+	err := json.Unmarshal(responseBody, &response)
+	if err != nil {
+		logger.Println("Could not parse weather", err)
+		w.weatherStatus = "wttr.in parse error"
+		return
+	}
+
+	if len(response.CurrentCondition) == 0 {
+		w.weatherStatus = "wttr.in no current weather"
+		return
+	}
+
+	current := response.CurrentCondition[0]
+	w.weatherStatus = ""
+	w.condition = weatherConditionFromCode(current.WeatherCode)
+	w.celsius, err = strconv.ParseFloat(current.TempC, 64)
+	if err != nil {
+		w.weatherStatus = "wttr.in bad temperature"
+		return
+	}
+	w.description = ""
+	if len(current.WeatherDesc) > 0 {
+		w.description = strings.TrimSpace(current.WeatherDesc[0].Value)
+	}
+
+	w.isDay = true
+	if len(response.Weather) > 0 && len(response.Weather[0].Astronomy) > 0 {
+		astronomy := response.Weather[0].Astronomy[0]
+		w.isDay = isDaytime(time.Now(), astronomy.Sunrise, astronomy.Sunset)
+	}
 }
 
 func (w *weatherProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
-	request, err := http.NewRequest("GET", "https://wttr.in?0&T&Q", nil)
+	url := w.url
+	if url == "" {
+		url = defaultWeatherURL
+	}
+	updateInterval := w.updateInterval
+	if updateInterval <= 0 {
+		updateInterval = 1 * time.Hour
+	}
+
+	request, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		logger.Println("Cannot create request", err)
 		return
 	}
 	request.Header["User-Agent"] = []string{"curl/8.0.1"}
 
-	client := http.Client{}
+	client := http.Client{Timeout: 30 * time.Second}
 
 	for {
 		{ // This block is so that the goto doesn't complain about jumping over a variable declaration
-			// response, err := http.Get("https://wttr.in?0&T&Q")
-			response, _ := client.Do(request)
-
-			status, err := strconv.ParseInt(response.Status[:3], 10, 32)
+			response, err := client.Do(request)
 			if err != nil {
-				logger.Println("Int parsing error", err)
+				// e.g. no network yet right after logging in
+				logger.Println("Could not fetch weather", err)
+				w.weatherStatus = "wttr.in unreachable"
+				changeChan <- blockChangedMessage{
+					index: index,
+				}
 				goto threadSleep
 			}
 
+			status := response.StatusCode
 			if status >= 200 && status < 300 {
 				responseBodyBytes, err := io.ReadAll(response.Body)
+				response.Body.Close()
 				if err != nil {
 					logger.Println("Error reading response body")
 					goto threadSleep
 				}
-				responseBody := string(responseBodyBytes)
-				logger.Println(responseBody)
-
-				lines := strings.SplitN(responseBody, "\n", 3)
-				firstValidCharacterIndex := 16
-				line1 := strings.Trim(lines[0][firstValidCharacterIndex:], " \n\t")
-				line2 := strings.Trim(lines[1][firstValidCharacterIndex:], " \n\t")
-				w.weatherStatus = fmt.Sprintf("%s %s", line1, line2)
+
+				w.updateFromResponse(responseBodyBytes)
 			} else {
+				response.Body.Close()
 				w.weatherStatus = fmt.Sprintf("wttr.in status code %d", status)
 			}
 
@@ -271,105 +738,305 @@ func (w *weatherProvider) monitor(changeChan chan<- blockChangedMessage, index i
 		}
 
 	threadSleep:
-		time.Sleep(1 * time.Hour)
+		select {
+		case <-time.After(updateInterval):
+		case <-w.refreshRequests():
+		}
+	}
+}
+
+// Only the last successful fetch is saved
+type weatherState struct {
+	Condition   weatherCondition `json:"condition"`
+	Description string           `json:"description"`
+	Celsius     float64          `json:"celsius"`
+	IsDay       bool             `json:"is_day"`
+}
+
+func (w *weatherProvider) stateKey() string {
+	return "weather"
+}
+
+func (w *weatherProvider) MarshalState() ([]byte, error) {
+	return json.Marshal(weatherState{w.condition, w.description, w.celsius, w.isDay})
+}
+
+func (w *weatherProvider) UnmarshalState(data []byte) error {
+	var state weatherState
+	err := json.Unmarshal(data, &state)
+	if err != nil {
+		return err
 	}
+
+	w.condition, w.description, w.celsius, w.isDay = state.Condition, state.Description, state.Celsius, state.IsDay
+	return nil
 }
 
 func (w *weatherProvider) createBlock() fullSwaybarMessageBodyBlock {
 	var block fullSwaybarMessageBodyBlock
 
-	block.FullText = w.weatherStatus
+	if w.weatherStatus != "" {
+		block.FullText = w.weatherStatus
+	} else if w.textOnly {
+		block.FullText = joinSegments(segmentSeparator, truncate(w.description, w.maxDescriptionLength), formatTemperature(w.celsius, w.unit))
+	} else {
+		icons := weatherIcons[w.condition]
+		icon := icons[1]
+		if w.isDay {
+			icon = icons[0]
+		}
+		block.FullText = fmt.Sprintf("%s %s", icon, formatTemperature(w.celsius, w.unit))
+		block.ShortText = icon
+	}
 
 	return block
 }
 
-func (weatherProvider) name() string {
-	return ""
+func (w *weatherProvider) name() string {
+	return "weather"
 }
 
-func (weatherProvider) respondToClick(event clickEvent) {
+func (w *weatherProvider) respondToClick(event clickEvent) {
 }
 
 // ---
 
 type ipAddressProvider struct {
-	text string
+	oneShotProvider
 }
 
-func (ip ipAddressProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
-	// This does not need to infinite-loop
+func newIPAddressProvider() *ipAddressProvider {
+	return &ipAddressProvider{
+		oneShotProvider{
+			fetch:     readLocalIPAddress,
+			updates:   watchAddressChanges,
+			shortText: "IP",
+		},
+	}
 }
 
-func (ip *ipAddressProvider) createBlock() fullSwaybarMessageBodyBlock {
-	var block fullSwaybarMessageBodyBlock
+func readLocalIPAddress() (string, error) {
+	hostnameOutput, err := exec.Command("hostname", "-I").Output()
+	if err != nil {
+		return "", err
+	}
 
-	if ip.text == "" {
-		hostnameOutput, err := exec.Command("hostname", "-I").Output()
-		if err != nil {
-			return block
-		}
+	localIPAddress := strings.SplitN(strings.TrimSpace(string(hostnameOutput)), " ", 2)[0]
+	if localIPAddress == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("IP:%s", localIPAddress), nil
+}
 
-		localIPAddress := strings.SplitN(string(hostnameOutput), " ", 2)[0]
-		ip.text = fmt.Sprintf("IP:%s", localIPAddress)
+// Sends whenever an address is added to or removed from an interface
+func watchAddressChanges() (<-chan struct{}, error) {
+	command := exec.Command("ip", "monitor", "address")
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
 	}
 
-	block.FullText = ip.text
+	err = command.Start()
+	if err != nil {
+		return nil, err
+	}
 
-	return block
+	changes := make(chan struct{})
+	go func() {
+		defer close(changes)
+		defer command.Wait()
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			changes <- struct{}{}
+		}
+	}()
+
+	return changes, nil
 }
 
-func (ipAddressProvider) name() string {
+func (ip *ipAddressProvider) name() string {
 	return "network"
 }
 
-func (ipAddressProvider) respondToClick(event clickEvent) {
-	exec.Command("alacritty", "--class", "network_manager", "-e", "nmtui").Run()
+func (ip *ipAddressProvider) respondToClick(event clickEvent) {
+	launchDetached("alacritty", "--class", "network_manager", "-e", "nmtui")
 }
 
 // ---
 
+// Shows one temperature. Without a label or path that is the hottest CPU core, from sysfs when
+// lm-sensors isn't installed. Several can be configured to show e.g. the GPU and NVMe drive as
+// well, which share one sensors poll.
 type temperatureProvider struct {
-	text string
+	celsius float64
+	valid   bool
+	unit    temperatureUnit
+
+	sensors *sensorsSource // Shared with the other blocks that read lm-sensors
+
+	chip        string // Pattern for the sensors chip name, e.g. "nvme-*". Any chip if empty.
+	label       string // e.g. "Composite"
+	labelPrefix string // Without a label, the hottest reading whose label starts with this. Defaults to "Core".
+	path        string // A sysfs file in millidegrees to read instead, e.g. /sys/class/hwmon/hwmon1/temp1_input
+
+	pollInterval time.Duration // For path and the thermal zones, defaults to a minute. See sensorsSource for sensors.
+
+	instance      string  // Tells the blocks apart in the output
+	glyph         string  // Optional
+	urgentCelsius float64 // 0 for never urgent
 }
 
-func (temp *temperatureProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
-	for {
-		sensorInfo, err := exec.Command("sensors").Output()
-		if err != nil {
-			logger.Panic(err)
+// Returns the hottest reading reported by lm-sensors whose label starts with prefix, e.g. the
+// hottest core for "Core"
+func hottestReading(readings sensorReadings, prefix string) (float64, error) {
+	hottest := 0.0
+	found := false
+	for _, chipReadings := range readings {
+		for label, celsius := range chipReadings {
+			if strings.HasPrefix(label, prefix) && (!found || celsius > hottest) {
+				hottest = celsius
+				found = true
+			}
 		}
+	}
 
-		maxNum := 0
-		maxString := ""
-		for _, line := range strings.Split(string(sensorInfo), "\n") {
-			if strings.HasPrefix(line, "Core") {
-				numIndex := strings.Index(line, "+") + 1
-				line = line[numIndex:]
+	if !found {
+		return 0, fmt.Errorf("no %s temperatures in sensors output", prefix)
+	}
 
-				numEndIndex := strings.Index(line, ".")
-				cIndex := strings.Index(line, "C") + 1
+	return hottest, nil
+}
 
-				num, err := strconv.Atoi(line[:numEndIndex])
-				if err != nil {
-					logger.Panic(err)
-				}
+func (temp *temperatureProvider) readingFrom(readings sensorReadings) (float64, error) {
+	if temp.label == "" {
+		prefix := temp.labelPrefix
+		if prefix == "" {
+			prefix = "Core"
+		}
+		return hottestReading(readings, prefix)
+	}
 
-				if num > maxNum {
-					maxNum = num
-					maxString = line[:cIndex]
-				}
+	chip := temp.chip
+	if chip == "" {
+		chip = "*"
+	}
 
-			}
+	celsius, found := readings.find(chip, temp.label)
+	if !found {
+		return 0, fmt.Errorf("no %q on a chip matching %q in sensors output", temp.label, chip)
+	}
+	return celsius, nil
+}
+
+func readMilliDegrees(path string) (float64, error) {
+	tempBytes, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	milliDegrees, err := strconv.Atoi(strings.TrimSpace(string(tempBytes)))
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(milliDegrees) / 1000, nil
+}
+
+const thermalZoneRoot = "/sys/class/thermal"
+
+// Thermal zone types that measure the CPU, most specific first
+var cpuThermalZoneTypes = []string{"x86_pkg_temp", "cpu-thermal", "cpu_thermal", "soc_thermal", "k10temp", "acpitz"}
+
+// Reads the CPU temperature in Celsius from sysfs for machines without lm-sensors
+func readThermalZoneTemperature(root string) (float64, error) {
+	zones, err := filepath.Glob(filepath.Join(root, "thermal_zone*"))
+	if err != nil {
+		return 0, err
+	}
+
+	zonesByType := make(map[string]string)
+	for _, zone := range zones {
+		zoneType, err := os.ReadFile(filepath.Join(zone, "type"))
+		if err != nil {
+			continue
 		}
+		zoneTypeString := strings.TrimSpace(string(zoneType))
+		if _, exists := zonesByType[zoneTypeString]; !exists {
+			zonesByType[zoneTypeString] = zone
+		}
+	}
 
-		if temp.text != maxString {
-			temp.text = maxString
+	for _, zoneType := range cpuThermalZoneTypes {
+		zone, exists := zonesByType[zoneType]
+		if !exists {
+			continue
+		}
+
+		return readMilliDegrees(filepath.Join(zone, "temp"))
+	}
+
+	return 0, fmt.Errorf("no CPU thermal zone in %s", root)
+}
+
+func (temp *temperatureProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	lastError := ""
+	update := func(celsius float64, err error) {
+		valid := err == nil
+		if !valid && err.Error() != lastError {
+			// Only once, since a sensor that isn't there stays missing
+			logger.Println("Could not read temperature", temp.instance, err)
+			lastError = err.Error()
+		} else if valid {
+			lastError = ""
+		}
+
+		if temp.celsius != celsius || temp.valid != valid {
+			temp.celsius = celsius
+			temp.valid = valid
 			changeChan <- blockChangedMessage{
 				index: index,
 			}
 		}
+	}
+
+	pollInterval := temp.pollInterval
+	if pollInterval <= 0 {
+		pollInterval = 1 * time.Minute
+	}
 
-		time.Sleep(1 * time.Minute)
+	if temp.path != "" {
+		for {
+			update(readMilliDegrees(temp.path))
+			time.Sleep(pollInterval)
+		}
+	}
+
+	_, err := exec.LookPath("sensors")
+	if err != nil {
+		if temp.label != "" {
+			logger.Println("sensors not found, can't read", temp.label)
+			return
+		}
+
+		logger.Println("sensors not found, reading temperature from", thermalZoneRoot)
+		for {
+			update(readThermalZoneTemperature(thermalZoneRoot))
+			time.Sleep(pollInterval)
+		}
+	}
+
+	if temp.sensors == nil {
+		temp.sensors = &sensorsSource{}
+	}
+
+	for range temp.sensors.subscribe() {
+		readings, err := temp.sensors.latest()
+		if err != nil {
+			update(0, err)
+			continue
+		}
+		update(temp.readingFrom(readings))
 	}
 }
 
@@ -377,11 +1044,29 @@ func (temp *temperatureProvider) createBlock() fullSwaybarMessageBodyBlock {
 	// /Core/ { X=substr($3, 2, 4)+0; if(X > M) M = X } END { print "  " M " °C " }
 	var block fullSwaybarMessageBodyBlock
 
-	block.FullText = "  " + temp.text
+	if temp.valid {
+		block.Instance = temp.instance
+		if temp.glyph != "" {
+			block.FullText = temp.glyph + " " + formatTemperature(temp.celsius, temp.unit)
+			block.ShortText = temp.glyph
+		} else {
+			block.FullText = " " + formatTemperature(temp.celsius, temp.unit)
+			block.ShortText = formatTemperature(temp.celsius, temp.unit)
+		}
+
+		if temp.urgentCelsius > 0 && temp.celsius >= temp.urgentCelsius {
+			urgent := true
+			block.Urgent = &urgent
+		}
+	}
 
 	return block
 }
 
+func (temp *temperatureProvider) blockInstance() string {
+	return temp.instance
+}
+
 func (temp *temperatureProvider) name() string {
 	return ""
 }
@@ -390,23 +1075,41 @@ func (temp *temperatureProvider) respondToClick(event clickEvent) {}
 
 // ---
 
-type timeMonitor struct{}
+type timeMonitor struct {
+	showSeconds bool
 
-func (timeMonitor) monitor(changeChan chan<- blockChangedMessage, index int) {
+	now time.Time // The time that is shown, set by monitor so that rendering doesn't read the clock
+}
+
+func (tm *timeMonitor) initialize() {
+	tm.now = time.Now()
+}
+
+func (tm *timeMonitor) monitor(changeChan chan<- blockChangedMessage, index int) {
 	for {
 		t := time.Now()
-		diff := 60 - t.Second()
-		time.Sleep(time.Duration(diff) * time.Second)
+		if tm.showSeconds {
+			// Wake up right on the second boundary so the display doesn't lag behind the real clock
+			time.Sleep(time.Until(t.Truncate(time.Second).Add(time.Second)))
+		} else {
+			diff := 60 - t.Second()
+			time.Sleep(time.Duration(diff) * time.Second)
+		}
+		tm.now = time.Now()
 		changeChan <- blockChangedMessage{
 			index: index,
 		}
 	}
 }
 
-func (timeMonitor) createBlock() fullSwaybarMessageBodyBlock {
+func (tm *timeMonitor) createBlock() fullSwaybarMessageBodyBlock {
 	block := fullSwaybarMessageBodyBlock{}
-	t := time.Now()
-	block.FullText = fmt.Sprintf("%s %s %02d, %d %02d:%02d", t.Weekday().String()[:3], t.Month().String()[:3], t.Day(), t.Year(), t.Hour(), t.Minute())
+	t := tm.now
+	block.FullText = t.Format("Mon Jan 02, 2006 15:04")
+	block.ShortText = t.Format("15:04")
+	if tm.showSeconds {
+		block.FullText += t.Format(":05")
+	}
 	return block
 }
 
@@ -455,7 +1158,7 @@ func (nc *notificationCenterMonitor) name() string {
 func (nc *notificationCenterMonitor) respondToClick(event clickEvent) {
 	// logger.Println("NC Received click", event)
 	if event.Button == 1 {
-		exec.Command("swaync-client", "-t", "-sw").Run()
+		launchDetached("swaync-client", "-t", "-sw")
 	}
 }
 
@@ -463,29 +1166,43 @@ type ncClientOutput struct {
 	Class any `json:"class"`
 }
 
-func (nc *notificationCenterMonitor) monitor(changeChan chan<- blockChangedMessage, index int) {
+// Runs swaync-client until it exits, keeping nc.state up to date
+func (nc *notificationCenterMonitor) followClient(changeChan chan<- blockChangedMessage, index int) error {
 	ncMonitor := exec.Command("swaync-client", "-swb")
 	stdout, err := ncMonitor.StdoutPipe()
 	if err != nil {
-		logger.Panic(err)
+		return err
 	}
+
+	err = ncMonitor.Start()
+	if err != nil {
+		return err
+	}
+	defer ncMonitor.Wait()
+
 	jsonDecoder := json.NewDecoder(stdout)
-	ncMonitor.Start()
 
 	for {
 		var ncStateOutput ncClientOutput
 		err = jsonDecoder.Decode(&ncStateOutput)
-		if err != nil {
-			logger.Panic(err)
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			// swaync went away, e.g. it was restarted
+			return err
+		} else if err != nil {
+			// The decoder can't resync after bad input, so start over with a fresh client
+			ncMonitor.Process.Kill()
+			return fmt.Errorf("malformed output from swaync-client: %w", err)
 		}
 
 		oldState := nc.state
 		nc.isOpen = false
 		if str, ok := ncStateOutput.Class.(string); ok {
 			nc.state = ncGetState(str)
-		} else if arr, ok := ncStateOutput.Class.([]any); ok {
-			nc.state = ncGetState(arr[0].(string))
-			if len(arr) > 1 && arr[1].(string) == "cc-open" {
+		} else if arr, ok := ncStateOutput.Class.([]any); ok && len(arr) > 0 {
+			if str, ok := arr[0].(string); ok {
+				nc.state = ncGetState(str)
+			}
+			if len(arr) > 1 && arr[1] == "cc-open" {
 				nc.isOpen = true
 			}
 		}
@@ -501,6 +1218,30 @@ func (nc *notificationCenterMonitor) monitor(changeChan chan<- blockChangedMessa
 	}
 }
 
+func (nc *notificationCenterMonitor) monitor(changeChan chan<- blockChangedMessage, index int) {
+	const initialBackoff = 1 * time.Second
+	const maxBackoff = 1 * time.Minute
+	backoff := initialBackoff
+
+	for {
+		started := time.Now()
+		err := nc.followClient(changeChan, index)
+		if time.Since(started) > maxBackoff {
+			// It ran fine for a while, so this isn't a crash loop
+			backoff = initialBackoff
+		}
+
+		// The last known state is kept on screen while waiting
+		logger.Println("swaync-client stopped, restarting in", backoff, err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
 func (nc *notificationCenterMonitor) createBlock() fullSwaybarMessageBodyBlock {
 	var result fullSwaybarMessageBodyBlock
 
@@ -554,7 +1295,7 @@ func (nc *notificationCenterMonitor) createBlock() fullSwaybarMessageBodyBlock {
 
 type clickEvent struct {
 	Name      string `json:"name"`
-	Instance  string `json:"instance"` // I don't currently set this
+	Instance  string `json:"instance"` // Only set for providers with an instance, see instancedProvider
 	X         int    `json:"x"`
 	Y         int    `json:"y"`
 	Button    int    `json:"button"`
@@ -563,38 +1304,223 @@ type clickEvent struct {
 	RelativeY int    `json:"relative_y"`
 	Width     int    `json:"width"`
 	Height    int    `json:"height"`
-}
 
-func decodeClickEvent(eventString string) clickEvent {
-	var result clickEvent
+	// Held while clicking, e.g. "Shift", "Control", "Mod1" or "Mod4". Only sent by newer
+	// versions of swaybar and i3bar, and empty otherwise.
+	Modifiers []string `json:"modifiers"`
+}
 
-	if eventString[0] == ',' {
-		eventString = eventString[1:]
+func (event clickEvent) hasModifier(modifier string) bool {
+	for _, held := range event.Modifiers {
+		if held == modifier {
+			return true
+		}
 	}
+	return false
+}
 
-	err := json.Unmarshal([]byte(eventString), &result)
+// Starts a program without waiting for it to exit so that click handlers return immediately
+func launchDetached(name string, args ...string) {
+	command := exec.Command(name, args...)
+	err := command.Start()
 	if err != nil {
-		logger.Panic(err)
+		logger.Println("Could not launch", name, err)
+		return
 	}
 
-	return result
+	// Reap the process whenever it exits
+	go command.Wait()
+}
+
+// Repeated clicks on the same block within this window are dropped so that an app isn't launched twice
+const clickDebounceInterval = 500 * time.Millisecond
+
+type clickKey struct {
+	name     string
+	instance string
+	button   int
+}
+
+func isScrollButton(button int) bool {
+	return button >= 4 && button <= 7
 }
 
 func updateSingleBlock(fullBlockValues []fullSwaybarMessageBodyBlock, index int, provider blockProvider) {
-	fullBlock := provider.createBlock()
+	fullBlock, err := renderBlock(provider)
+	if err != nil {
+		logger.Println("Could not render block", index, provider.name(), err)
+		fullBlock = errorBlock(provider)
+	}
+	stats.recordRender(index, err)
+
+	// Error blocks are styled too, so that they stay in line with the others
+	blockSettingsAt(index).apply(&fullBlock)
 
 	// Set name here to make sure that it responds to clicks if it needs to
 	fullBlock.Name = provider.name()
+	if instance := providerInstance(provider); instance != "" {
+		fullBlock.Instance = instance
+	}
 	fullBlockValues[index] = fullBlock
 }
 
+// The config of each block by its index in blockProviders, like stats
+var blockSettings []BlockConfig
+
+// Blocks without settings, e.g. in tests, have the defaults
+func blockSettingsAt(index int) BlockConfig {
+	if index < len(blockSettings) {
+		return blockSettings[index]
+	}
+	return BlockConfig{}
+}
+
 func updateFullBlockValues(fullBlockValues []fullSwaybarMessageBodyBlock, blockProviders []blockProvider) {
 	for i, provider := range blockProviders {
 		updateSingleBlock(fullBlockValues, i, provider)
 	}
 }
 
-func displayStatusBar(fullBlockValues []fullSwaybarMessageBodyBlock, blockProviders []blockProvider, indexToUpdate int) {
+// When set, every block gets this background while any one block is urgent, so that something
+// needing attention can't be missed. swaybar's own bar colors are left alone.
+var urgentBarBackground string
+
+// Returns the blocks to send, leaving the rendered blocks untouched so that they go back to
+// normal once nothing is urgent
+func applyUrgentBarBackground(fullBlockValues []fullSwaybarMessageBodyBlock) []fullSwaybarMessageBodyBlock {
+	if urgentBarBackground == "" {
+		return fullBlockValues
+	}
+
+	anyUrgent := false
+	for _, block := range fullBlockValues {
+		if block.Urgent != nil && *block.Urgent {
+			anyUrgent = true
+			break
+		}
+	}
+	if !anyUrgent {
+		return fullBlockValues
+	}
+
+	result := make([]fullSwaybarMessageBodyBlock, len(fullBlockValues))
+	for i, block := range fullBlockValues {
+		block.Background = urgentBarBackground
+		result[i] = block
+	}
+	return result
+}
+
+// Clicking any block with this button hides it for hideDuration. 0 turns hiding off.
+// Blocks need a name to receive clicks, so only named blocks can be hidden.
+var hideButton int
+var hideDuration = 1 * time.Hour
+
+// Returns the blocks to send with hidden ones emptied, leaving the rendered blocks untouched
+func hideBlocks(fullBlockValues []fullSwaybarMessageBodyBlock, hiddenUntil []time.Time) []fullSwaybarMessageBodyBlock {
+	now := time.Now()
+	result := fullBlockValues
+	copied := false
+	for i, until := range hiddenUntil {
+		if !now.Before(until) {
+			continue
+		}
+
+		if !copied {
+			result = append([]fullSwaybarMessageBodyBlock{}, fullBlockValues...)
+			copied = true
+		}
+		result[i] = fullSwaybarMessageBodyBlock{Name: fullBlockValues[i].Name}
+	}
+	return result
+}
+
+// Space after the rightmost block, between it and the edge of the screen or the tray. Negative
+// leaves it to swaybar. Swaybar skips blocks without text, so this goes on the last one with text.
+var edgeMargin = -1
+
+func setEdgeMargin(fullBlockValues []fullSwaybarMessageBodyBlock) []fullSwaybarMessageBodyBlock {
+	if edgeMargin < 0 {
+		return fullBlockValues
+	}
+
+	for i := len(fullBlockValues) - 1; i >= 0; i-- {
+		if fullBlockValues[i].FullText == "" {
+			continue
+		}
+
+		result := append([]fullSwaybarMessageBodyBlock{}, fullBlockValues...)
+		separator := false
+		margin := edgeMargin
+		result[i].Separator = &separator
+		result[i].SeparatorBlockWidth = &margin
+		return result
+	}
+
+	return fullBlockValues
+}
+
+// Swaybar switches every block to its short_text when the full texts don't fit. Without this the
+// short texts are dropped and swaybar cuts off whatever doesn't fit instead.
+var compactWhenNarrow = true
+
+func dropShortTexts(fullBlockValues []fullSwaybarMessageBodyBlock) []fullSwaybarMessageBodyBlock {
+	if compactWhenNarrow {
+		return fullBlockValues
+	}
+
+	result := make([]fullSwaybarMessageBodyBlock, len(fullBlockValues))
+	for i, block := range fullBlockValues {
+		block.ShortText = ""
+		result[i] = block
+	}
+	return result
+}
+
+// Moves urgent blocks to the left end of the bar while they are urgent. The order within the
+// urgent and the other blocks stays as configured, so blocks only move when urgency changes.
+var urgentFirst bool
+
+func moveUrgentFirst(fullBlockValues []fullSwaybarMessageBodyBlock) []fullSwaybarMessageBodyBlock {
+	if !urgentFirst {
+		return fullBlockValues
+	}
+
+	isUrgent := func(block fullSwaybarMessageBodyBlock) bool {
+		return block.Urgent != nil && *block.Urgent
+	}
+
+	result := make([]fullSwaybarMessageBodyBlock, 0, len(fullBlockValues))
+	for _, block := range fullBlockValues {
+		if isUrgent(block) {
+			result = append(result, block)
+		}
+	}
+	for _, block := range fullBlockValues {
+		if !isUrgent(block) {
+			result = append(result, block)
+		}
+	}
+	return result
+}
+
+// Blocks that set a background but no text color get black or white text, whichever is readable.
+// Runs last so that it sees the background that is actually sent.
+func setContrastingTextColors(fullBlockValues []fullSwaybarMessageBodyBlock) []fullSwaybarMessageBodyBlock {
+	result := make([]fullSwaybarMessageBodyBlock, len(fullBlockValues))
+	for i, block := range fullBlockValues {
+		if block.Background != "" && block.Color == "" {
+			if background, ok := parseColor(block.Background); ok {
+				block.Color = contrastingTextColor(background)
+			}
+		}
+		result[i] = block
+	}
+	return result
+}
+
+func displayStatusBar(out io.Writer, fullBlockValues []fullSwaybarMessageBodyBlock, blockProviders []blockProvider, order *blockOrder, hiddenUntil []time.Time, indexToUpdate int) {
+	start := time.Now()
 	if indexToUpdate < 0 {
 		logger.Println("Updating all blocks")
 		updateFullBlockValues(fullBlockValues, blockProviders)
@@ -603,19 +1529,30 @@ func displayStatusBar(fullBlockValues []fullSwaybarMessageBodyBlock, blockProvid
 		updateSingleBlock(fullBlockValues, indexToUpdate, blockProviders[indexToUpdate])
 	}
 
-	bytes, err := json.Marshal(fullBlockValues)
+	// What is sent can differ from what the providers rendered
+	blocks := hideBlocks(fullBlockValues, hiddenUntil)
+	blocks = order.apply(blocks)
+	blocks = moveUrgentFirst(blocks)
+	blocks = applyUrgentBarBackground(blocks)
+	blocks = setContrastingTextColors(blocks)
+	blocks = replaceGlyphs(blocks)
+	blocks = dropShortTexts(blocks)
+	blocks = setEdgeMargin(blocks)
+
+	bytes, err := json.Marshal(blocks)
 	if err != nil {
 		logger.Panic(err)
 	}
 	str := string(bytes)
 	logger.Println("Data", str)
-	fmt.Println(str, ",")
+	fmt.Fprintln(out, str, ",")
+	stats.recordDisplay(time.Since(start))
 }
 
 func defaultHeader() swaybarMessageHeader {
 	result := swaybarMessageHeader{
-		Version:     1,
-		ClickEvents: true,
+		Version:     swaybarProtocolVersion,
+		ClickEvents: outputFifos == "",
 		ContSignal:  syscall.SIGCONT,
 		StopSignal:  syscall.SIGSTOP,
 	}
@@ -623,34 +1560,164 @@ func defaultHeader() swaybarMessageHeader {
 	return result
 }
 
-func mainLoop(stdinChannel <-chan clickEvent, blockChanged <-chan blockChangedMessage, blockProviders []blockProvider) {
-	stdinNeverWriteToMe := make(<-chan clickEvent) // This channel is never written to and so it always blocks. This is in case stdinChannel is closed
-	fullBlockValues := make([]fullSwaybarMessageBodyBlock, len(blockProviders))
+// What click events and control commands find a block by
+type blockKey struct {
+	name     string
+	instance string
+}
+
+// Click events are routed by name and instance, so two providers with the same pair would mean
+// that only one of them ever receives clicks. The first provider with a pair keeps it and an
+// error is returned.
+func mapProvidersByName(blockProviders []blockProvider) (map[blockKey]int, error) {
+	providersByName := make(map[blockKey]int)
+	duplicates := []string{}
 
-	providersByName := make(map[string]int)
 	for i, block := range blockProviders {
-		name := block.name()
-		if name != "" {
-			providersByName[name] = i
+		key := blockKey{block.name(), providerInstance(block)}
+		if key.name == "" {
+			continue
 		}
+
+		if existing, exists := providersByName[key]; exists {
+			description := fmt.Sprintf("%q", key.name)
+			if key.instance != "" {
+				description += fmt.Sprintf(" instance %q", key.instance)
+			}
+			duplicates = append(duplicates, fmt.Sprintf("%s (blocks %d and %d)", description, existing, i))
+			continue
+		}
+		providersByName[key] = i
+	}
+
+	if len(duplicates) > 0 {
+		return providersByName, fmt.Errorf("duplicate block names, only the first block with each name and instance receives clicks: %s", strings.Join(duplicates, ", "))
 	}
 
+	return providersByName, nil
+}
+
+/*
+   Startup happens in this order, so that the first frame swaybar draws is already filled in
+   rather than a row of empty blocks that are replaced a moment later:
+
+   1. initialize() runs on every provider that has it, one after the other, before any monitor
+      starts. It is for values that are quick to read, like the IP address or the time.
+   2. The monitors start and fetch their first values in parallel.
+   3. The header and the start of the infinite array are sent, so swaybar knows the protocol
+      straight away.
+   4. Changes from the monitors are collected until each one has reported once, or until
+      initialRenderTimeout has passed. Monitors that only report changes (e.g. a hidden block)
+      or that are slow (e.g. the weather) don't hold up the bar for longer than that.
+   5. Every block is rendered and sent once, and after that only changed blocks are.
+*/
+
+// How long the first render waits for the monitors
+const initialRenderTimeout = 250 * time.Millisecond
+
+// Returns once every provider has sent a change or the timeout has passed
+func awaitInitialState(blockChanged <-chan blockChangedMessage, providerCount int, timeout time.Duration) {
+	reported := make([]bool, providerCount)
+	remaining := providerCount
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for remaining > 0 {
+		select {
+		case changeInfo := <-blockChanged:
+			if !reported[changeInfo.index] {
+				reported[changeInfo.index] = true
+				remaining--
+			}
+		case <-timer.C:
+			logger.Println("Rendering before", remaining, "blocks reported")
+			return
+		}
+	}
+}
+
+func mainLoop(out io.Writer, stdinChannel <-chan clickEvent, blockChanged <-chan blockChangedMessage, blockProviders []blockProvider, order *blockOrder) {
+	stdinNeverWriteToMe := make(<-chan clickEvent) // This channel is never written to and so it always blocks. This is in case stdinChannel is closed
+	fullBlockValues := make([]fullSwaybarMessageBodyBlock, len(blockProviders))
+
+	providersByName, err := mapProvidersByName(blockProviders)
+	if err != nil {
+		logger.Println(err)
+	}
+
+	lastClicks := make(map[clickKey]time.Time)
+
+	controlRequests := make(chan controlRequest)
+	listenForControl(controlRequests)
+
+	lastUpdates := make([]time.Time, len(blockProviders))
+	pendingUpdates := make([]bool, len(blockProviders))
+	delayedUpdates := make(chan int)
+	hiddenUntil := make([]time.Time, len(blockProviders))
+
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGCONT, syscall.SIGSTOP)
+	signal.Notify(signals, syscall.SIGCONT, syscall.SIGSTOP, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
 
+	stats.started = time.Now()
 	header := defaultHeader()
 
-	sendHeader(header)
-	fmt.Print("[")
+	sendHeader(out, header)
 
-	displayStatusBar(fullBlockValues, blockProviders, -1)
+	awaitInitialState(blockChanged, len(blockProviders), initialRenderTimeout)
+	displayStatusBar(out, fullBlockValues, blockProviders, order, hiddenUntil, -1)
 
 	for {
 		select {
 		case event, isOpen := <-stdinChannel:
 			if isOpen {
-				providerIndex := providersByName[event.Name]
-				blockProviders[providerIndex].respondToClick(event)
+				if debugLogging {
+					eventJson, _ := json.Marshal(event)
+					logger.Println("Click", string(eventJson))
+				}
+
+				providerIndex, exists := providersByName[blockKey{event.Name, event.Instance}]
+				if !exists {
+					logger.Println("Ignoring click on block without a provider:", event.Name)
+					break
+				}
+
+				// Scrolling is meant to repeat, so it isn't debounced
+				if !isScrollButton(event.Button) {
+					key := clickKey{event.Name, event.Instance, event.Button}
+					now := time.Now()
+					if now.Sub(lastClicks[key]) < clickDebounceInterval {
+						logger.Println("Ignoring repeated click on", event.Name)
+						break
+					}
+					lastClicks[key] = now
+				}
+
+				if hideButton != 0 && event.Button == hideButton {
+					logger.Println("Hiding", event.Name, "for", hideDuration)
+					hiddenUntil[providerIndex] = time.Now().Add(hideDuration)
+					time.AfterFunc(hideDuration, func() { delayedUpdates <- providerIndex })
+					displayStatusBar(out, fullBlockValues, blockProviders, order, hiddenUntil, providerIndex)
+					break
+				}
+
+				if event.Button != 0 && (event.Button == moveLeftButton || event.Button == moveRightButton) {
+					direction := 1
+					if event.Button == moveLeftButton {
+						direction = -1
+					}
+
+					isVisible := func(index int) bool { return fullBlockValues[index].FullText != "" }
+					if order.move(providerIndex, direction, isVisible) {
+						logger.Println("Moved", event.Name, "to", order.order)
+						displayStatusBar(out, fullBlockValues, blockProviders, order, hiddenUntil, providerIndex)
+					}
+					break
+				}
+
+				// Handlers may block (e.g. waiting on a command), which must not stop the bar from updating
+				go blockProviders[providerIndex].respondToClick(event)
 			} else {
 				stdinChannel = stdinNeverWriteToMe
 			}
@@ -661,37 +1728,71 @@ func mainLoop(stdinChannel <-chan clickEvent, blockChanged <-chan blockChangedMe
 			} else if signal == syscall.SIGSTOP {
 				logger.Println("SIGSTOP")
 				return
+			} else {
+				// swaybar sends SIGTERM when it exits or reloads
+				logger.Println(signal)
+				return
 			}
 
 		case changeInfo := <-blockChanged:
-			displayStatusBar(fullBlockValues, blockProviders, changeInfo.index)
+			index := changeInfo.index
+			stats.recordChange(index)
+			if pendingUpdates[index] {
+				// The delayed redraw will pick up this change too
+				break
+			}
+
+			wait := minUpdateInterval(index) - time.Since(lastUpdates[index])
+			if wait > 0 {
+				pendingUpdates[index] = true
+				time.AfterFunc(wait, func() { delayedUpdates <- index })
+				break
+			}
+
+			lastUpdates[index] = time.Now()
+			displayStatusBar(out, fullBlockValues, blockProviders, order, hiddenUntil, index)
+
+		case request := <-controlRequests:
+			request.reply <- handleControlRequest(request, blockProviders, providersByName)
+
+		case index := <-delayedUpdates:
+			pendingUpdates[index] = false
+			lastUpdates[index] = time.Now()
+			displayStatusBar(out, fullBlockValues, blockProviders, order, hiddenUntil, index)
 		}
 	}
 }
 
+// swaybar writes click events as an infinite JSON array, one object per click. Decoding the
+// stream directly instead of line by line doesn't depend on how swaybar spaces and separates them.
 func setupStdinReader() <-chan clickEvent {
 	stdinChannel := make(chan clickEvent, 1)
 	go func(stdinChannel chan<- clickEvent) {
-		reader := bufio.NewReader(os.Stdin)
-		for {
-			buffer, err := reader.ReadString('\n')
-			if err != nil { // Maybe log non io.EOF errors, if you want
-				if err == io.EOF {
-					close(stdinChannel)
-					break
-				}
-				panic(err)
+		defer close(stdinChannel)
+		decoder := json.NewDecoder(os.Stdin)
+
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				logger.Println("Could not read click events", err)
 			}
+			return
+		}
+		if delimiter, ok := token.(json.Delim); !ok || delimiter != '[' {
+			logger.Println("Click events did not start with [, got", token)
+			return
+		}
 
-			trimmed := strings.Trim(buffer, " \n")
-			if trimmed == "[" {
-				// skip first [
-			} else if trimmed == "]" {
-				close(stdinChannel)
+		// More is false once the closing ] is next, or at the end of input
+		for decoder.More() {
+			var event clickEvent
+			err := decoder.Decode(&event)
+			if err != nil {
+				// There is no way to find the start of the next event in a broken stream
+				logger.Println("Could not decode click event", err)
 				return
-			} else {
-				stdinChannel <- decodeClickEvent(trimmed)
 			}
+			stdinChannel <- event
 		}
 	}(stdinChannel)
 
@@ -699,9 +1800,20 @@ func setupStdinReader() <-chan clickEvent {
 }
 
 func setupBlockChangeNotifier(blockProviders []blockProvider) <-chan blockChangedMessage {
+	// The main loop still answers swaybar and the control socket, it just never redraws.
+	// An empty array is valid, so swaybar shows an empty bar rather than waiting on us.
+	if len(blockProviders) == 0 {
+		logger.Println("Warning: no blocks are configured, the bar will be empty")
+		return nil // A nil channel never sends
+	}
+
 	blockChanged := make(chan blockChangedMessage)
 
-	// Update swaybar with initial info so you don't have to wait until a block updates
+	for _, block := range blockProviders {
+		initializeProvider(block)
+	}
+
+	// The first render waits a moment for these, see awaitInitialState
 	for index, block := range blockProviders {
 		go block.monitor(blockChanged, index)
 	}
@@ -711,6 +1823,24 @@ func setupBlockChangeNotifier(blockProviders []blockProvider) <-chan blockChange
 
 var logger *log.Logger
 
+// The blocks shown when config.toml doesn't list any, from left to right. The others are opt-in.
+var defaultBlocks = []string{
+	"volume",
+	"weather",
+	"network",
+	"temperature",
+	// Bluetooth
+	"time",
+	"notification-center",
+}
+
+// Comma separated paths of named pipes to write the bar to instead of stdout, one per bar that
+// reads it. See fifoOutput.
+var outputFifos string
+
+// Logs extra detail that is only useful when diagnosing a problem, like every click event
+var debugLogging bool
+
 func setupLogger() *os.File {
 	path, err := os.Executable()
 	if err != nil {
@@ -730,29 +1860,255 @@ func setupLogger() *os.File {
 }
 
 func main() {
+	flag.BoolVar(&debugLogging, "debug", false, "Log every click event as JSON")
+	flag.BoolVar(&persistState, "persist-state", false, "Save the last value of some blocks on exit and show it again on the next start")
+	flag.BoolVar(&urgentFirst, "urgent-first", false, "Move urgent blocks to the left end of the bar until they aren't urgent")
+	flag.StringVar(&urgentBarBackground, "urgent-background", "", "Background `#RRGGBB` of every block while any block is urgent")
+	flag.IntVar(&hideButton, "hide-button", 0, "Mouse button that hides a block when clicked, e.g. 2 for middle click. 0 turns hiding off.")
+	flag.IntVar(&moveLeftButton, "move-left-button", 0, "Mouse button that moves a block one place to the left, e.g. 8 for the back button. 0 turns moving off.")
+	flag.IntVar(&moveRightButton, "move-right-button", 0, "Mouse button that moves a block one place to the right, e.g. 9 for the forward button")
+	flag.DurationVar(&hideDuration, "hide-duration", hideDuration, "How long a block stays hidden with -hide-button")
+	flag.BoolVar(&compactWhenNarrow, "compact-when-narrow", compactWhenNarrow, "Show each block's short form, usually just its glyph, when the bar doesn't fit the output")
+	flag.IntVar(&edgeMargin, "edge-margin", edgeMargin, "Pixels between the rightmost block and the edge of the bar, without a separator. 0 puts the block flush against the edge, negative leaves it to swaybar.")
+	flag.StringVar(&outputFifos, "output-fifo", "", "Comma separated named pipes to write the bar to instead of stdout, one per bar, e.g. for a top and a bottom bar. Created if missing. Clicks aren't received this way.")
+	flag.BoolVar(&collectStats, "collect-stats", false, "Count updates and errors per block for -stats")
+	volumeMuteDisplay := muteGlyph
+	flag.Func("mute-display", "How the volume block shows a muted channel: glyph, word, red or strikethrough. Defaults to glyph.", func(value string) (err error) {
+		volumeMuteDisplay, err = parseMuteDisplay(value)
+		return err
+	})
+	mediaFormat := flag.String("media-format", "{artist} - {title}", "What the media block shows, with the placeholders {artist}, {title}, {album}, {player} and {status}")
+	mediaMaxLength := flag.Int("media-max-length", 40, "Characters the media block shows before cutting off with …, 0 for no limit")
+	mediaState := flag.Bool("media-state", true, "Start the media block with a play or pause glyph instead of a note")
+	flag.Var(glyphReplacements, "glyph", "Replace a glyph the font lacks, as `glyph=text` where glyph is the glyph itself or its code point like U+F0E7. Can be repeated.")
+	flag.BoolVar(&asciiGlyphs, "ascii-glyphs", false, "Drop every Nerd Font glyph that has no -glyph replacement, for fonts without them")
+	flag.StringVar(&segmentSeparator, "separator", segmentSeparator, "Text between the values of blocks that show several, e.g. \" | \"")
+	refreshBlock := flag.String("refresh", "", "Ask the running status bar to update the named block now and exit. See control.go for the names.")
+	notify := flag.String("notify", "", "Show a message in the running status bar for a while and exit")
+	idle := flag.Bool("idle", false, "Tell the running status bar that the session went idle and exit. Meant for a swayidle timeout, see idle.go.")
+	showStats := flag.Bool("stats", false, "Print the running status bar's update counts, errors and render times and exit. Needs -collect-stats on the bar.")
+	active := flag.Bool("active", false, "Tell the running status bar that the session is active again and exit. Meant for swayidle's resume.")
+	flag.Parse()
+
+	// Runs before the logger is set up so that the running bar's log isn't truncated
+	controlCommand := ""
+	if *refreshBlock != "" {
+		controlCommand = "refresh " + *refreshBlock
+	} else if *notify != "" {
+		controlCommand = "notify " + *notify
+	} else if *idle {
+		controlCommand = "idle"
+	} else if *active {
+		controlCommand = "active"
+	} else if *showStats {
+		controlCommand = "stats"
+	}
+
+	if controlCommand != "" {
+		reply, err := sendControlCommand(controlCommand)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		var indented bytes.Buffer
+		if *showStats && json.Indent(&indented, []byte(reply), "", "  ") == nil {
+			reply = indented.String()
+		}
+		fmt.Println(reply)
+		return
+	}
+
 	logsFile := setupLogger()
 	defer logsFile.Close()
 
-	volume := volumeProvider{}
-	weather := weatherProvider{}
-	ipProvider := ipAddressProvider{}
-	temperature := temperatureProvider{}
+	// Flags on the command line win over the config files, see config.go
+	config, err := loadConfig()
+	if err == nil {
+		err = applyConfig(flag.CommandLine, config)
+	}
+	exitOnConfigError(err)
+
+	volume := volumeProvider{
+		muteDisplay: volumeMuteDisplay,
+		step:        volumeStep,
+	}
+	weather := weatherProvider{
+		maxDescriptionLength: 20,
+	}
+	ipProvider := newIPAddressProvider()
+	sensors := &sensorsSource{
+		interval: 1 * time.Minute,
+	}
+	temperature := temperatureProvider{
+		sensors:       sensors,
+		urgentCelsius: 90,
+	}
+	fan := fanProvider{
+		sensors:         sensors,
+		label:           "fan1",
+		hideWhenStopped: true,
+	}
+	nvmeTemperature := temperatureProvider{
+		sensors:       sensors,
+		chip:          "nvme-*",
+		label:         "Composite",
+		instance:      "nvme",
+		glyph:         "󰋊",
+		urgentCelsius: 70,
+	}
 	timeProvider := timeMonitor{}
 	ncProvider := notificationCenterMonitor{}
+	scratchpad := scratchpadProvider{}
+	fullscreen := fullscreenProvider{}
+	keyboardLayout := keyboardLayoutProvider{}
+	swayLayout := swayLayoutProvider{
+		clicked: make(chan struct{}, 1),
+	}
+	battery := batteryProvider{
+		notifyThresholds: []int{15, 5},
+		lowPercent:       10,
+		pollInterval:     30 * time.Second,
+	}
+	screenShare := screenShareProvider{}
+	cpuCores := cpuCoresProvider{}
+	cpu := cpuProvider{}
+	memory := memoryProvider{
+		urgentPercent:  90,
+		usePressure:    true,
+		urgentPressure: 10,
+		showPercent:    true,
+		viewer:         []string{"alacritty", "--class", "htop", "-e", "htop"},
+	}
+	tasks := taskProvider{
+		manager: []string{"alacritty", "--class", "tasks", "-e", "taskwarrior-tui"},
+	}
+	updates := updatesProvider{
+		urgentCount:    50,
+		upgradeCommand: []string{"alacritty", "--class", "updates", "-e", "sudo", "pacman", "-Syu"},
+	}
+	message := messageProvider{
+		duration: 10 * time.Second,
+		messages: make(chan string, 8),
+	}
+	rss := rssProvider{
+		reader: []string{"alacritty", "--class", "newsboat", "-e", "newsboat"},
+	}
+	media := mediaProvider{
+		format:    *mediaFormat,
+		maxLength: *mediaMaxLength,
+		showState: *mediaState,
+	}
+	torrents := torrentProvider{
+		client:       transmissionClient,
+		manager:      []string{"transmission-gtk"},
+		hideWhenIdle: true,
+	}
+	peripheralBatteries := peripheralBatteryProvider{
+		lowPercent: 10,
+	}
+	diskHealth := smartProvider{
+		device: "/dev/nvme0",
+	}
+	nightLight := nightLightProvider{
+		command:     []string{"gammastep", "-O", "4000"},
+		temperature: 4000,
+		clicked:     make(chan struct{}, 1),
+	}
+	idleLock := idleProvider{
+		lockIn:       1 * time.Minute,
+		urgentWithin: 15 * time.Second,
+		events:       make(chan bool, 4),
+	}
+	dbusProperty := newDBusPropertyProvider()
+	wifiSignal := wifiSignalProvider{
+		levelThresholds: []int{25, 50, 75},
+	}
 
-	blockProviders := []blockProvider{
-		&volume,
-		&weather,
-		&ipProvider,
-		&temperature,
-		// battery
+	// The names used in config.toml, see blockconfig.go
+	providers := map[string]blockProvider{
+		"message":              &message,
+		"idle":                 &idleLock,
+		"screen-share":         &screenShare,
+		"fullscreen":           &fullscreen,
+		"layout":               &swayLayout,
+		"scratchpad":           &scratchpad,
+		"tasks":                &tasks,
+		"updates":              &updates,
+		"rss":                  &rss,
+		"torrents":             &torrents,
+		"keyboard-layout":      &keyboardLayout,
+		"night-light":          &nightLight,
+		"media":                &media,
+		"volume":               &volume,
+		"weather":              &weather,
+		"network":              ipProvider,
+		"wifi":                 &wifiSignal,
+		"cpu":                  &cpu,
+		"cpu-cores":            &cpuCores,
+		"memory":               &memory,
+		"temperature":          &temperature,
+		"nvme-temperature":     &nvmeTemperature,
+		"fan":                  &fan,
+		"disk-health":          &diskHealth,
+		"battery":              &battery,
+		"peripheral-batteries": &peripheralBatteries,
 		// Bluetooth
-		timeProvider,
-		&ncProvider,
+		"time":                &timeProvider,
+		"notification-center": &ncProvider,
+	}
+	if dbusSupported {
+		providers["dbus-property"] = dbusProperty
+	}
+
+	blockConfig, err := loadBlockConfig(config, providers)
+	exitOnConfigError(err)
+	exitOnConfigError(config.checkUnknown())
+
+	exitOnConfigError(blockConfig.Volume.apply(&volume))
+	exitOnConfigError(blockConfig.Weather.apply(&weather))
+	exitOnConfigError(blockConfig.Temperature.apply(&temperature))
+	blockConfig.CPU.apply(&cpu)
+	blockConfig.Memory.apply(&memory)
+	blockConfig.Time.apply(&timeProvider)
+	exitOnConfigError(blockConfig.Battery.apply(&battery))
+	exitOnConfigError(blockConfig.Wifi.apply(&wifiSignal))
+	exitOnConfigError(blockConfig.DBusProperty.apply(dbusProperty))
+
+	layout, err := blockConfig.Bar.layout(providers)
+	exitOnConfigError(err)
+
+	blockProviders := layout.providers()
+	blockSettings = blockConfig.settingsInOrder(blockProviders, providers)
+
+	order := newBlockOrder(blockProviders)
+	savedState := persistentProviders(blockProviders)
+	savedState[order.stateKey()] = order
+
+	if persistState {
+		loadBlockState(savedState)
 	}
 
 	stdinChannel := setupStdinReader()
 	blockChanged := setupBlockChangeNotifier(blockProviders)
 
-	mainLoop(stdinChannel, blockChanged, blockProviders)
+	var out io.Writer = os.Stdout
+	if outputFifos != "" {
+		writers := []io.Writer{}
+		for _, path := range strings.Split(outputFifos, ",") {
+			fifo, err := newFifoOutput(path)
+			if err != nil {
+				logger.Println("Could not use output pipe", err)
+				os.Exit(1)
+			}
+			writers = append(writers, fifo)
+		}
+		out = io.MultiWriter(writers...)
+	}
+
+	mainLoop(out, stdinChannel, blockChanged, blockProviders, order)
+
+	if persistState {
+		saveBlockState(savedState)
+	}
 }
@@ -0,0 +1,682 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden files in testdata with the current output")
+
+func TestMain(m *testing.M) {
+	logger = log.New(io.Discard, "", 0)
+	os.Exit(m.Run())
+}
+
+// A provider with fixed state for testing what the bar does with the blocks it renders
+type fakeProvider struct {
+	blockName string
+	instance  string
+	block     fullSwaybarMessageBodyBlock
+}
+
+func (fp *fakeProvider) monitor(changeChan chan<- blockChangedMessage, index int) {}
+
+func (fp *fakeProvider) createBlock() fullSwaybarMessageBodyBlock {
+	return fp.block
+}
+
+func (fp *fakeProvider) name() string {
+	return fp.blockName
+}
+
+func (fp *fakeProvider) respondToClick(event clickEvent) {}
+
+func (fp *fakeProvider) blockInstance() string {
+	return fp.instance
+}
+
+func TestMapProvidersByName(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers []blockProvider
+		want      map[blockKey]int
+		wantError string // Empty for no error
+	}{
+		{
+			name: "unique names",
+			providers: []blockProvider{
+				&fakeProvider{blockName: "volume"},
+				&fakeProvider{blockName: "weather"},
+			},
+			want: map[blockKey]int{{"volume", ""}: 0, {"weather", ""}: 1},
+		},
+		{
+			name: "unnamed blocks are skipped",
+			providers: []blockProvider{
+				&fakeProvider{},
+				&fakeProvider{},
+				&fakeProvider{blockName: "time"},
+			},
+			want: map[blockKey]int{{"time", ""}: 2},
+		},
+		{
+			name: "same name with different instances",
+			providers: []blockProvider{
+				&fakeProvider{blockName: "temperature", instance: "cpu"},
+				&fakeProvider{blockName: "temperature", instance: "nvme"},
+			},
+			want: map[blockKey]int{{"temperature", "cpu"}: 0, {"temperature", "nvme"}: 1},
+		},
+		{
+			name: "same name",
+			providers: []blockProvider{
+				&fakeProvider{blockName: "volume"},
+				&fakeProvider{blockName: "weather"},
+				&fakeProvider{blockName: "volume"},
+			},
+			want:      map[blockKey]int{{"volume", ""}: 0, {"weather", ""}: 1},
+			wantError: `"volume" (blocks 0 and 2)`,
+		},
+		{
+			name: "same name and instance",
+			providers: []blockProvider{
+				&fakeProvider{blockName: "temperature", instance: "cpu"},
+				&fakeProvider{blockName: "temperature", instance: "cpu"},
+			},
+			want:      map[blockKey]int{{"temperature", "cpu"}: 0},
+			wantError: `"temperature" instance "cpu" (blocks 0 and 1)`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mapProvidersByName(test.providers)
+
+			if test.wantError == "" && err != nil {
+				t.Errorf("unexpected error %v", err)
+			} else if test.wantError != "" && (err == nil || !strings.Contains(err.Error(), test.wantError)) {
+				t.Errorf("error = %v, want one containing %s", err, test.wantError)
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for key, index := range test.want {
+				if gotIndex, exists := got[key]; !exists || gotIndex != index {
+					t.Errorf("got %v, want %v", got, test.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Lays out thermal zones like /sys/class/thermal, each with its type and temperature file
+func writeThermalZones(t *testing.T, zones map[string][2]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for zone, contents := range zones {
+		directory := filepath.Join(root, zone)
+		if err := os.Mkdir(directory, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(directory, "type"), []byte(contents[0]+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if contents[1] != "" {
+			if err := os.WriteFile(filepath.Join(directory, "temp"), []byte(contents[1]+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestReadThermalZoneTemperature(t *testing.T) {
+	tests := []struct {
+		name      string
+		zones     map[string][2]string // Zone directory to type and temp
+		want      float64
+		wantError bool
+	}{
+		{
+			name: "intel laptop",
+			zones: map[string][2]string{
+				"thermal_zone0": {"acpitz", "27800"},
+				"thermal_zone1": {"INT3400 Thermal", "20000"},
+				"thermal_zone2": {"x86_pkg_temp", "45000"},
+			},
+			want: 45,
+		},
+		{
+			name: "acpitz is only used without a more specific zone",
+			zones: map[string][2]string{
+				"thermal_zone0": {"acpitz", "27800"},
+				"thermal_zone1": {"iwlwifi_1", "38000"},
+			},
+			want: 27.8,
+		},
+		{
+			name: "raspberry pi",
+			zones: map[string][2]string{
+				"thermal_zone0": {"cpu-thermal", "51540"},
+			},
+			want: 51.54,
+		},
+		{
+			name: "no cpu zone",
+			zones: map[string][2]string{
+				"thermal_zone0": {"iwlwifi_1", "38000"},
+				"thermal_zone1": {"pch_cannonlake", "41000"},
+			},
+			wantError: true,
+		},
+		{
+			name:      "no zones",
+			zones:     map[string][2]string{},
+			wantError: true,
+		},
+		{
+			name: "unreadable temperature",
+			zones: map[string][2]string{
+				"thermal_zone0": {"x86_pkg_temp", ""},
+			},
+			wantError: true,
+		},
+		{
+			name: "garbage temperature",
+			zones: map[string][2]string{
+				"thermal_zone0": {"x86_pkg_temp", "hot"},
+			},
+			wantError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readThermalZoneTemperature(writeThermalZones(t, test.zones))
+			if test.wantError {
+				if err == nil {
+					t.Errorf("got %v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+const sampleWttrResponse = `{
+	"current_condition": [{"temp_C": "12", "weatherCode": "296", "weatherDesc": [{"value": " Light rain "}]}],
+	"weather": [{"astronomy": [{"sunrise": "12:00 AM", "sunset": "11:59 PM"}]}]
+}`
+
+func TestWeatherUpdateFromResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus string
+	}{
+		{"j1", sampleWttrResponse, ""},
+		// What wttr.in answers in its one-line format, or when it is overloaded
+		{"one line", "Oslo: 🌦 +12°C\n", "wttr.in parse error"},
+		{"empty", "", "wttr.in parse error"},
+		{"html", "<html><body>Bad Gateway</body></html>", "wttr.in parse error"},
+		{"no current condition", `{"current_condition": []}`, "wttr.in no current weather"},
+		{"bad temperature", `{"current_condition": [{"temp_C": "warm"}]}`, "wttr.in bad temperature"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weather := weatherProvider{}
+			weather.updateFromResponse([]byte(test.body))
+			if weather.weatherStatus != test.wantStatus {
+				t.Errorf("Status is %q, want %q", weather.weatherStatus, test.wantStatus)
+			}
+
+			// Whatever came back, the block has something to show
+			if block := weather.createBlock(); block.FullText == "" {
+				t.Error("Block has no text")
+			}
+		})
+	}
+}
+
+func TestWeatherUpdateFromResponseFields(t *testing.T) {
+	weather := weatherProvider{}
+	weather.updateFromResponse([]byte(sampleWttrResponse))
+
+	if weather.condition != conditionRain {
+		t.Errorf("Condition is %v, want rain", weather.condition)
+	}
+	if weather.celsius != 12 {
+		t.Errorf("Temperature is %v, want 12", weather.celsius)
+	}
+	if weather.description != "Light rain" {
+		t.Errorf("Description is %q, want it trimmed", weather.description)
+	}
+
+	// A failed update after a good one doesn't keep showing the old weather as current
+	weather.updateFromResponse([]byte("Unknown location"))
+	if weather.weatherStatus == "" {
+		t.Error("A bad response after a good one has no status")
+	}
+}
+
+// Waits for the first update from the weather block fetching from handler, or from nowhere if
+// handler is nil
+func firstWeatherBlock(t *testing.T, handler http.HandlerFunc) fullSwaybarMessageBodyBlock {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	url := server.URL
+	if handler == nil {
+		server.Close()
+	} else {
+		defer server.Close()
+	}
+
+	weather := &weatherProvider{url: url, updateInterval: time.Hour}
+	changes := make(chan blockChangedMessage)
+	go weather.monitor(changes, 0)
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The weather block never updated")
+	}
+	return weather.createBlock()
+}
+
+func TestWeatherMonitor(t *testing.T) {
+	block := firstWeatherBlock(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, sampleWttrResponse)
+	})
+	if !strings.HasSuffix(block.FullText, "12°C") {
+		t.Errorf("Block is %q, want the temperature", block.FullText)
+	}
+
+	block = firstWeatherBlock(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	})
+	if block.FullText != "wttr.in status code 503" {
+		t.Errorf("Block is %q after a 503", block.FullText)
+	}
+
+	// This used to crash on the nil response
+	block = firstWeatherBlock(t, nil)
+	if block.FullText != "wttr.in unreachable" {
+		t.Errorf("Block is %q with nothing to connect to", block.FullText)
+	}
+}
+
+func TestSetContrastingTextColors(t *testing.T) {
+	blocks := []fullSwaybarMessageBodyBlock{
+		{Background: "#FFFF00"},
+		{Background: "#000080"},
+		{Background: "#FFFF00", Color: "#FF0000"}, // Chosen by the block
+		{Background: "not a color"},
+		{},
+	}
+	want := []string{"#000000", "#FFFFFF", "#FF0000", "", ""}
+
+	result := setContrastingTextColors(blocks)
+	for i, block := range result {
+		if block.Color != want[i] {
+			t.Errorf("Block %d has text color %q, want %q", i, block.Color, want[i])
+		}
+	}
+	if blocks[0].Color != "" {
+		t.Error("The blocks passed in were changed")
+	}
+}
+
+// Pointer fields are left out when unset, but an explicit false or 0 is still sent since swaybar's
+// defaults are true and non-zero for some of them
+func TestBlockJSONOmitsUnsetFields(t *testing.T) {
+	zero, two := 0, 2
+	no, yes := false, true
+
+	tests := []struct {
+		name     string
+		block    fullSwaybarMessageBodyBlock
+		wantKeys []string
+	}{
+		{"only text", fullSwaybarMessageBodyBlock{FullText: "a"}, []string{"full_text"}},
+		{"empty text", fullSwaybarMessageBodyBlock{}, []string{"full_text"}},
+		{"urgent", fullSwaybarMessageBodyBlock{FullText: "a", Urgent: &yes}, []string{"full_text", "urgent"}},
+		{"not urgent", fullSwaybarMessageBodyBlock{FullText: "a", Urgent: &no}, []string{"full_text", "urgent"}},
+		{"no separator", fullSwaybarMessageBodyBlock{FullText: "a", Separator: &no}, []string{"full_text", "separator"}},
+		{"separator width 0", fullSwaybarMessageBodyBlock{FullText: "a", SeparatorBlockWidth: &zero}, []string{"full_text", "separator_block_width"}},
+		{"min width 0", fullSwaybarMessageBodyBlock{FullText: "a", MinWidth: &zero}, []string{"full_text", "min_width"}},
+		{"min width", fullSwaybarMessageBodyBlock{FullText: "a", MinWidth: &two}, []string{"full_text", "min_width"}},
+		{
+			"borders",
+			fullSwaybarMessageBodyBlock{FullText: "a", Border: "#FF0000", BorderTop: &zero, BorderBottom: &two, BorderLeft: &zero, BorderRight: &zero},
+			[]string{"border", "border_bottom", "border_left", "border_right", "border_top", "full_text"},
+		},
+		{"one border", fullSwaybarMessageBodyBlock{FullText: "a", BorderBottom: &two}, []string{"border_bottom", "full_text"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := json.Marshal(test.block)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var decoded map[string]any
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			keys := make([]string, 0, len(decoded))
+			for key := range decoded {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			if !reflect.DeepEqual(keys, test.wantKeys) {
+				t.Errorf("%s has keys %v, want %v", encoded, keys, test.wantKeys)
+			}
+		})
+	}
+}
+
+// Compares got with testdata/name, or rewrites it with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("Could not read the golden file, run the test with -update to create it:", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output differs from %s, run the test with -update if the change is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+type failingProvider struct {
+	fakeProvider
+}
+
+func (fp *failingProvider) createBlockOrError() (fullSwaybarMessageBodyBlock, error) {
+	return fullSwaybarMessageBodyBlock{}, errors.New("no data")
+}
+
+// Real providers with fixed state through everything that happens between rendering and sending
+func TestDisplayStatusBarGolden(t *testing.T) {
+	previousUrgentFirst, previousEdgeMargin := urgentFirst, edgeMargin
+	t.Cleanup(func() { urgentFirst, edgeMargin = previousUrgentFirst, previousEdgeMargin })
+	urgentFirst, edgeMargin = true, 0
+
+	weather := &weatherProvider{}
+	weather.updateFromResponse([]byte(sampleWttrResponse))
+	weather.isDay = true // The sample's sunset depends on when the test runs
+
+	memory := &memoryProvider{
+		valid:       true,
+		showPercent: true,
+		usage:       memoryUsage{used: 6 << 30, total: 16 << 30},
+	}
+	cpu := &cpuProvider{valid: true, percent: 95, corePercents: []int{90, 100}}
+	message := &fakeProvider{blockName: "message", block: fullSwaybarMessageBodyBlock{FullText: "Build finished"}}
+	broken := &failingProvider{fakeProvider{blockName: "broken"}}
+
+	providers := []blockProvider{weather, memory, cpu, message, broken}
+	fullBlockValues := make([]fullSwaybarMessageBodyBlock, len(providers))
+	order := newBlockOrder(providers)
+	hiddenUntil := make([]time.Time, len(providers))
+
+	var out bytes.Buffer
+	displayStatusBar(&out, fullBlockValues, providers, order, hiddenUntil, -1)
+
+	// The memory block turning urgent moves it to the front
+	memory.urgent = true
+	displayStatusBar(&out, fullBlockValues, providers, order, hiddenUntil, 1)
+
+	// A hidden block keeps its name but sends no text
+	hiddenUntil[3] = time.Now().Add(time.Hour)
+	displayStatusBar(&out, fullBlockValues, providers, order, hiddenUntil, 3)
+
+	assertGolden(t, "display_status_bar.golden", out.Bytes())
+}
+
+func TestWeatherTextOnly(t *testing.T) {
+	weather := weatherProvider{maxDescriptionLength: 20}
+	if err := (WeatherConfig{TextOnly: true}).apply(&weather); err != nil {
+		t.Fatal(err)
+	}
+	weather.updateFromResponse([]byte(sampleWttrResponse))
+
+	block := weather.createBlock()
+	if block.FullText != "Light rain 12°C" {
+		t.Errorf("Block is %q, want the description instead of the icon", block.FullText)
+	}
+}
+
+func TestTemperatureBlock(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      temperatureProvider
+		wantFullText  string
+		wantShortText string
+	}{
+		{"default glyph", temperatureProvider{valid: true, celsius: 45.4}, "\uf2db 45°C", "45°C"},
+		{"fahrenheit", temperatureProvider{valid: true, celsius: 45.4, unit: unitFahrenheit}, "\uf2db 114°F", "114°F"},
+		{"own glyph", temperatureProvider{valid: true, celsius: 60, glyph: "\U000f02ca"}, "\U000f02ca 60°C", "\U000f02ca"},
+		{"no reading", temperatureProvider{celsius: 60}, "", ""},
+	}
+
+	for _, test := range tests {
+		block := test.provider.createBlock()
+		if block.FullText != test.wantFullText || block.ShortText != test.wantShortText {
+			t.Errorf("%s: got %q and %q, want %q and %q", test.name, block.FullText, block.ShortText, test.wantFullText, test.wantShortText)
+		}
+	}
+}
+
+// mainLoop writes from its own goroutine while the test reads
+type lockedBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(data []byte) (int, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.buffer.Write(data)
+}
+
+func (lb *lockedBuffer) String() string {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.buffer.String()
+}
+
+// Runs mainLoop like main does, without click events, until the test ends. It is then stopped
+// with SIGTERM like swaybar stops it.
+func startMainLoop(t *testing.T, providers []blockProvider) *lockedBuffer {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	out := &lockedBuffer{}
+	done := make(chan struct{})
+	blockChanged := setupBlockChangeNotifier(providers)
+	go func() {
+		defer close(done)
+		mainLoop(out, nil, blockChanged, providers, newBlockOrder(providers))
+	}()
+
+	t.Cleanup(func() {
+		// Until mainLoop handles SIGTERM it would end the whole test run. It does by the time the
+		// header is sent.
+		deadline := time.Now().Add(time.Second)
+		for !strings.Contains(out.String(), "\n[") {
+			if time.Now().After(deadline) {
+				t.Error("mainLoop never sent the header, leaving it running")
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("mainLoop didn't return on SIGTERM")
+		}
+	})
+	return out
+}
+
+// Waits until mainLoop has sent the header and at least count renders, and returns the renders
+func waitForRenders(t *testing.T, out *lockedBuffer, count int, timeout time.Duration) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		output := out.String()
+		header, body, sentHeader := strings.Cut(output, "\n[")
+		if sentHeader {
+			var decoded map[string]any
+			if err := json.Unmarshal([]byte(header), &decoded); err != nil || decoded["version"] == nil {
+				t.Fatalf("Header %q isn't a swaybar header: %v", header, err)
+			}
+
+			renders := strings.SplitAfter(body, " ,\n")
+			renders = renders[:len(renders)-1] // Empty, or a render that is still being written
+			if len(renders) >= count {
+				return renders
+			}
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Got %q after %v, want the header and %d renders", output, timeout, count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// swaybar waits for the header and a first array, so the bar must not wait on blocks that
+// don't exist
+func TestMainLoopWithoutProviders(t *testing.T) {
+	out := startMainLoop(t, nil)
+
+	renders := waitForRenders(t, out, 1, time.Second)
+	if renders[0] != "[] ,\n" {
+		t.Errorf("First render is %q, want an empty array", renders[0])
+	}
+	if strings.Count(out.String(), "\n[") != 1 {
+		t.Errorf("Header sent more than once: %q", out.String())
+	}
+}
+
+// Shows text once its monitor reports, after delay. A negative delay never reports.
+type reportingProvider struct {
+	fakeProvider
+	text  string
+	delay time.Duration
+}
+
+func (rp *reportingProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if rp.delay < 0 {
+		return
+	}
+	time.Sleep(rp.delay)
+	rp.block.FullText = rp.text
+	changeChan <- blockChangedMessage{index: index}
+}
+
+func TestMainLoopWaitsForInitialState(t *testing.T) {
+	providers := []blockProvider{
+		&reportingProvider{fakeProvider: fakeProvider{blockName: "fast"}, text: "fast"},
+		&reportingProvider{fakeProvider: fakeProvider{blockName: "slow"}, text: "slow", delay: 50 * time.Millisecond},
+	}
+	out := startMainLoop(t, providers)
+
+	// Drawn once, already filled in, rather than empty and then once per block
+	renders := waitForRenders(t, out, 1, time.Second)
+	want := `[{"full_text":"fast","name":"fast"},{"full_text":"slow","name":"slow"}] ,` + "\n"
+	if renders[0] != want {
+		t.Errorf("First render is %q, want %q", renders[0], want)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if renders := waitForRenders(t, out, 1, time.Second); len(renders) != 1 {
+		t.Errorf("Rendered again without a change: %q", renders)
+	}
+}
+
+func TestMainLoopInitialRenderTimeout(t *testing.T) {
+	providers := []blockProvider{
+		&reportingProvider{fakeProvider: fakeProvider{blockName: "fast"}, text: "fast"},
+		&reportingProvider{fakeProvider: fakeProvider{blockName: "silent", block: fullSwaybarMessageBodyBlock{FullText: "waiting"}}, delay: -1},
+	}
+	start := time.Now()
+	out := startMainLoop(t, providers)
+
+	renders := waitForRenders(t, out, 1, time.Second)
+	if elapsed := time.Since(start); elapsed < initialRenderTimeout {
+		t.Errorf("Rendered after %v, before the %v timeout", elapsed, initialRenderTimeout)
+	}
+
+	// The silent block is drawn with whatever it has
+	want := `[{"full_text":"fast","name":"fast"},{"full_text":"waiting","name":"silent"}] ,` + "\n"
+	if renders[0] != want {
+		t.Errorf("First render is %q, want %q", renders[0], want)
+	}
+}
+
+func TestAwaitInitialState(t *testing.T) {
+	blockChanged := make(chan blockChangedMessage, 3)
+
+	// A block reporting twice doesn't make up for one that hasn't reported
+	blockChanged <- blockChangedMessage{index: 0}
+	blockChanged <- blockChangedMessage{index: 0}
+	start := time.Now()
+	awaitInitialState(blockChanged, 2, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Returned after %v without every block reporting", elapsed)
+	}
+
+	blockChanged <- blockChangedMessage{index: 1}
+	blockChanged <- blockChangedMessage{index: 0}
+	start = time.Now()
+	awaitInitialState(blockChanged, 2, time.Minute)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Waited %v after every block reported", elapsed)
+	}
+}
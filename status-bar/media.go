@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// What playerctl prints for each change, one field per placeholder
+const mediaMetadataFormat = "{{status}}\t{{artist}}\t{{title}}\t{{album}}\t{{playerName}}"
+
+type mediaState struct {
+	status string // Playing, Paused or Stopped. Empty when there is no player.
+	artist string
+	title  string
+	album  string
+	player string
+}
+
+// Shows what the current MPRIS player is playing, through playerctl
+type mediaProvider struct {
+	state mediaState
+
+	// Placeholders are {artist}, {title}, {album}, {player} and {status}, like playerctl's
+	// without the doubled braces. Defaults to "{artist} - {title}".
+	format    string
+	maxLength int  // Cut off with "…", 0 for no limit
+	showState bool // Start with a play or pause glyph instead of a note
+}
+
+func parseMediaMetadata(line string) mediaState {
+	fields := strings.Split(line, "\t")
+	for len(fields) < 5 {
+		fields = append(fields, "")
+	}
+	return mediaState{
+		status: fields[0],
+		artist: fields[1],
+		title:  fields[2],
+		album:  fields[3],
+		player: fields[4],
+	}
+}
+
+func (mp *mediaProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if _, err := exec.LookPath("playerctl"); err != nil {
+		logger.Println("playerctl not found, the media block stays hidden")
+		return
+	}
+
+	for {
+		// Prints a line whenever anything changes and an empty one when the last player closes
+		playerctl := exec.Command("playerctl", "--follow", "metadata", "--format", mediaMetadataFormat)
+		stdout, err := playerctl.StdoutPipe()
+		if err == nil {
+			err = playerctl.Start()
+		}
+		if err != nil {
+			logger.Println("Could not start playerctl", err)
+			return
+		}
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			state := parseMediaMetadata(scanner.Text())
+			if state != mp.state {
+				mp.state = state
+				changeChan <- blockChangedMessage{
+					index: index,
+				}
+			}
+		}
+
+		err = playerctl.Wait()
+		logger.Println("playerctl exited, starting it again", err)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (mp *mediaProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when nothing is playing or paused
+	state := mp.state
+	if state.status == "" || state.status == "Stopped" {
+		return block
+	}
+
+	format := mp.format
+	if format == "" {
+		format = "{artist} - {title}"
+	}
+
+	text := strings.NewReplacer(
+		"{artist}", state.artist,
+		"{title}", state.title,
+		"{album}", state.album,
+		"{player}", state.player,
+		"{status}", state.status,
+	).Replace(format)
+
+	// Left behind by empty placeholders, e.g. a stream without an artist
+	text = strings.TrimSpace(text)
+	text = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text, "- "), " -"))
+
+	glyph := ""
+	if mp.showState && state.status == "Playing" {
+		glyph = ""
+	} else if mp.showState {
+		glyph = ""
+	}
+
+	block.FullText = joinSegments(" ", glyph, truncate(text, mp.maxLength))
+	block.ShortText = glyph
+
+	return block
+}
+
+func (mp *mediaProvider) name() string {
+	return "media"
+}
+
+// Left click plays or pauses, right click skips to the next track
+func (mp *mediaProvider) respondToClick(event clickEvent) {
+	command := ""
+	switch event.Button {
+	case 1:
+		command = "play-pause"
+	case 3:
+		command = "next"
+	default:
+		return
+	}
+
+	err := exec.Command("playerctl", command).Run()
+	if err != nil {
+		logger.Println("playerctl", command, "failed", err)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values from /proc/meminfo in kB
+func readMeminfo() (map[string]uint64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// MemTotal:       16314256 kB
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		number, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		result[key] = number
+	}
+
+	return result, scanner.Err()
+}
+
+const memoryPressurePath = "/proc/pressure/memory"
+
+// Returns the "some avg10" value of the memory pressure stall information, which is the
+// percentage of the last 10 seconds in which at least one task was waiting on memory
+func readMemoryPressure() (float64, error) {
+	contents, err := os.ReadFile(memoryPressurePath)
+	if err != nil {
+		return 0, err
+	}
+
+	// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			if value, found := strings.CutPrefix(field, "avg10="); found {
+				return strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("no some avg10 in %s", memoryPressurePath)
+}
+
+// Sizes in kB
+type memoryUsage struct {
+	used      uint64
+	total     uint64
+	swapUsed  uint64
+	swapTotal uint64
+}
+
+func (usage memoryUsage) percentUsed() float64 {
+	return float64(usage.used) * 100 / float64(usage.total)
+}
+
+func (usage memoryUsage) swapPercentUsed() float64 {
+	if usage.swapTotal == 0 {
+		return 0
+	}
+	return float64(usage.swapUsed) * 100 / float64(usage.swapTotal)
+}
+
+func readMemoryUsage() (memoryUsage, error) {
+	var usage memoryUsage
+
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return usage, err
+	}
+
+	usage.total = meminfo["MemTotal"]
+	if usage.total == 0 {
+		return usage, fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+	available := meminfo["MemAvailable"]
+	if available > usage.total {
+		available = usage.total
+	}
+	usage.used = usage.total - available
+
+	usage.swapTotal = meminfo["SwapTotal"]
+	swapFree := meminfo["SwapFree"]
+	if swapFree > usage.swapTotal {
+		swapFree = usage.swapTotal
+	}
+	usage.swapUsed = usage.swapTotal - swapFree
+
+	return usage, nil
+}
+
+type memoryProvider struct {
+	usage  memoryUsage // As last shown, see threshold
+	valid  bool
+	urgent bool
+
+	urgentPercent int // Used when pressure isn't available or enabled
+
+	// Use pressure stall information to decide when memory is a problem, since a full page cache
+	// or a large but idle process can show a high percentage without anything slowing down
+	usePressure       bool
+	urgentPressure    float64 // some avg10 percentage
+	pressureAvailable bool
+
+	showPercent bool          // Otherwise used and total in GiB, e.g. 4.1/16.0G
+	showSwap    bool          // Also show swap the same way, if there is any
+	interval    time.Duration // Defaults to 5 seconds
+	threshold   float64       // Percentage points a value has to move to update the block, defaults to 1
+	viewer      []string      // Run on click
+}
+
+func (mem *memoryProvider) isUrgent(usage memoryUsage) bool {
+	if mem.usePressure && mem.pressureAvailable {
+		pressure, err := readMemoryPressure()
+		if err == nil {
+			return pressure >= mem.urgentPressure
+		}
+		logger.Println("Could not read memory pressure", err)
+	}
+
+	return mem.urgentPercent > 0 && usage.percentUsed() >= float64(mem.urgentPercent)
+}
+
+// Whether usage is far enough from what the block shows to update it
+func (mem *memoryProvider) hasMoved(usage memoryUsage, threshold float64) bool {
+	if !mem.valid {
+		return true
+	}
+	if math.Abs(usage.percentUsed()-mem.usage.percentUsed()) >= threshold {
+		return true
+	}
+	return mem.showSwap && math.Abs(usage.swapPercentUsed()-mem.usage.swapPercentUsed()) >= threshold
+}
+
+func (mem *memoryProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if mem.usePressure {
+		_, err := os.Stat(memoryPressurePath)
+		mem.pressureAvailable = err == nil
+		if !mem.pressureAvailable {
+			logger.Println("No memory pressure information, falling back to percentage used", err)
+		}
+	}
+
+	interval := mem.interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	threshold := mem.threshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+
+	for {
+		usage, err := readMemoryUsage()
+		if err != nil {
+			logger.Println("Could not read memory usage", err)
+		} else if urgent := mem.isUrgent(usage); mem.hasMoved(usage, threshold) || urgent != mem.urgent {
+			mem.usage, mem.valid, mem.urgent = usage, true, urgent
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// e.g. 4.1/16.0G
+func formatMemoryUsage(usedKB, totalKB uint64) string {
+	const kBPerGiB = 1024 * 1024
+	return fmt.Sprintf("%.1f/%.1fG", float64(usedKB)/kBPerGiB, float64(totalKB)/kBPerGiB)
+}
+
+func (mem *memoryProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	if !mem.valid {
+		return block
+	}
+
+	usage := mem.usage
+	if mem.showPercent {
+		block.FullText = fmt.Sprintf("󰍛 %d%%", int(usage.percentUsed()))
+	} else {
+		block.FullText = "󰍛 " + formatMemoryUsage(usage.used, usage.total)
+	}
+	if mem.showSwap && usage.swapTotal > 0 {
+		if mem.showPercent {
+			block.FullText += fmt.Sprintf(" swap %d%%", int(usage.swapPercentUsed()))
+		} else {
+			block.FullText += " swap " + formatMemoryUsage(usage.swapUsed, usage.swapTotal)
+		}
+	}
+	block.ShortText = "󰍛"
+	if mem.urgent {
+		urgent := true
+		block.Urgent = &urgent
+	}
+
+	return block
+}
+
+func (mem *memoryProvider) name() string {
+	return "memory"
+}
+
+// Opens the process viewer, e.g. htop
+func (mem *memoryProvider) respondToClick(event clickEvent) {
+	if event.Button == 1 && len(mem.viewer) > 0 {
+		launchDetached(mem.viewer[0], mem.viewer[1:]...)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const defaultMessageDuration = 10 * time.Second
+
+// Tick payloads starting with this are shown as messages, e.g.
+//
+//	swaymsg -t send_tick "status-bar:build done"
+const messageTickPrefix = "status-bar:"
+
+// Longer messages are cut off with "…"
+const maxMessageLength = 80
+
+// Providers that show messages sent with -notify implement this. It must not block.
+type notifiableProvider interface {
+	showMessage(text string)
+}
+
+// Shows a message from a script for a while, e.g. status-bar -notify "download complete".
+// A new message replaces the current one and starts the duration again.
+type messageProvider struct {
+	text string
+
+	duration time.Duration // Defaults to defaultMessageDuration
+	messages chan string
+}
+
+// Sends the payload of every tick meant for the bar
+func watchMessageTicks(messages chan<- string) {
+	subscription, err := swaySubscribe("tick")
+	if err != nil {
+		logger.Println("Could not subscribe to tick events, messages only come from the control socket", err)
+		return
+	}
+	defer subscription.Close()
+
+	for {
+		_, payload, err := subscription.ReadEvent()
+		if err != nil {
+			logger.Println("Tick event subscription closed", err)
+			return
+		}
+
+		var tick struct {
+			First   bool   `json:"first"`
+			Payload string `json:"payload"`
+		}
+		if json.Unmarshal(payload, &tick) != nil || tick.First {
+			continue
+		}
+
+		if text, isMessage := strings.CutPrefix(tick.Payload, messageTickPrefix); isMessage {
+			messages <- text
+		}
+	}
+}
+
+func (mp *messageProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	duration := mp.duration
+	if duration <= 0 {
+		duration = defaultMessageDuration
+	}
+
+	ticks := make(chan string)
+	go watchMessageTicks(ticks)
+
+	// Nil until there is a message to clear
+	var clear <-chan time.Time
+
+	for {
+		var text string
+		select {
+		case text = <-mp.messages:
+		case text = <-ticks:
+		case <-clear:
+		}
+
+		text = strings.TrimSpace(text)
+		clear = nil
+		if text != "" {
+			clear = time.After(duration)
+		}
+
+		if text != mp.text {
+			mp.text = text
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+}
+
+func (mp *messageProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when there is no message
+	if mp.text != "" {
+		block.FullText = " " + truncate(mp.text, maxMessageLength)
+		block.ShortText = ""
+	}
+
+	return block
+}
+
+func (mp *messageProvider) name() string {
+	return "message"
+}
+
+// Clicking dismisses the message
+func (mp *messageProvider) respondToClick(event clickEvent) {
+	if event.Button == 1 {
+		mp.showMessage("")
+	}
+}
+
+func (mp *messageProvider) showMessage(text string) {
+	select {
+	case mp.messages <- text:
+	default:
+		logger.Println("Dropping message, too many at once:", text)
+	}
+}
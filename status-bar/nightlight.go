@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Night light tools such as gammastep and wlsunset have no way to ask whether they are running or
+// to toggle them, so this block starts and stops the tool itself. Night mode is on while the
+// tool is running, whoever started it.
+type nightLightProvider struct {
+	active bool
+
+	command     []string // Keeps the screen warm until it is stopped, e.g. gammastep -O 4000
+	temperature int      // Kelvin, only for display
+
+	clicked chan struct{}
+}
+
+func (nl *nightLightProvider) processName() string {
+	return filepath.Base(nl.command[0])
+}
+
+func (nl *nightLightProvider) isRunning() bool {
+	return exec.Command("pgrep", "-x", nl.processName()).Run() == nil
+}
+
+func (nl *nightLightProvider) start(exited chan<- struct{}) {
+	command := exec.Command(nl.command[0], nl.command[1:]...)
+	err := command.Start()
+	if err != nil {
+		logger.Println("Could not start", nl.processName(), err)
+		return
+	}
+
+	go func() {
+		command.Wait()
+		select {
+		case exited <- struct{}{}:
+		default: // Already pending
+		}
+	}()
+}
+
+// Also stops instances that weren't started here
+func (nl *nightLightProvider) stop() {
+	err := exec.Command("pkill", "-x", nl.processName()).Run()
+	if err != nil {
+		logger.Println("Could not stop", nl.processName(), err)
+	}
+}
+
+func (nl *nightLightProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if len(nl.command) == 0 {
+		logger.Println("No night light command configured")
+		return
+	}
+
+	update := func(active bool) {
+		if active != nl.active {
+			nl.active = active
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+
+	update(nl.isRunning())
+
+	exited := make(chan struct{}, 1)
+
+	// Picks up the tool being started or stopped from outside the status bar
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-nl.clicked:
+			// The process takes a moment to exit, so pgrep can't be trusted right after a toggle
+			if nl.active {
+				nl.stop()
+				update(false)
+			} else {
+				nl.start(exited)
+				update(true)
+			}
+			continue
+		case <-exited:
+		case <-ticker.C:
+		}
+		update(nl.isRunning())
+	}
+}
+
+func (nl *nightLightProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	if nl.active {
+		block.FullText = fmt.Sprintf("󰖔 %dK", nl.temperature)
+		block.ShortText = "󰖔"
+	} else {
+		block.FullText = "󰖙"
+	}
+
+	return block
+}
+
+func (nl *nightLightProvider) name() string {
+	return "night light"
+}
+
+func (nl *nightLightProvider) respondToClick(event clickEvent) {
+	if event.Button != 1 {
+		return
+	}
+
+	select {
+	case nl.clicked <- struct{}{}:
+	default: // Already pending
+	}
+}
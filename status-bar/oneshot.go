@@ -0,0 +1,86 @@
+package main
+
+// Providers that can work out their value before the first render implement this. It is called
+// once at startup, before any monitor runs, so the first frame already has the value in it
+// instead of an empty block.
+type initializedProvider interface {
+	initialize()
+}
+
+func initializeProvider(provider blockProvider) {
+	if initialized, ok := provider.(initializedProvider); ok {
+		initialized.initialize()
+	}
+}
+
+// Base for blocks whose text is fetched once at startup and after that only changes on the
+// odd event, e.g. the IP address. Embed it and set fetch. The text is fetched again every time
+// the channel returned by updates sends or a refresh is requested. If updates is nil the block
+// only changes when refreshed.
+type oneShotProvider struct {
+	refreshTrigger
+
+	fetch   func() (string, error)
+	updates func() (<-chan struct{}, error)
+
+	text      string
+	shortText string // Shown instead of the text when the bar is crowded
+}
+
+// Returns true if the text changed
+func (p *oneShotProvider) refresh() bool {
+	text, err := p.fetch()
+	if err != nil {
+		logger.Println("Could not update block", err)
+		return false
+	}
+
+	if text == p.text {
+		return false
+	}
+	p.text = text
+	return true
+}
+
+func (p *oneShotProvider) initialize() {
+	p.refresh()
+}
+
+func (p *oneShotProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	// A nil channel never sends, so without updates only refreshes wake this up
+	var events <-chan struct{}
+	if p.updates != nil {
+		var err error
+		events, err = p.updates()
+		if err != nil {
+			logger.Println("Block will only update when refreshed", err)
+		}
+	}
+
+	for {
+		select {
+		case _, isOpen := <-events:
+			if !isOpen {
+				events = nil
+				continue
+			}
+		case <-p.refreshRequests():
+		}
+
+		if p.refresh() {
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+}
+
+func (p *oneShotProvider) createBlock() fullSwaybarMessageBodyBlock {
+	block := fullSwaybarMessageBodyBlock{
+		FullText: p.text,
+	}
+	if p.text != "" {
+		block.ShortText = p.shortText
+	}
+	return block
+}
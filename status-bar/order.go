@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+/*
+   Blocks can be moved left and right while the bar is running, e.g. to keep the blocks that
+   matter right now next to each other.
+
+   Swaybar's click events don't say which modifier keys were held and it has no drag events, so
+   moving uses two mouse buttons of its own, by default none. The back and forward side buttons
+   (8 and 9) work well since no block uses them. Each click moves the block past one visible
+   neighbour, skipping blocks that are empty at the moment. At either end of the bar the click
+   does nothing. Only named blocks receive clicks, so only they can be moved, but any block can
+   be moved past. Moving past a spacer moves the block into the next group.
+
+   With -persist-state the order is saved with the other state and restored on the next start,
+   unless the configured blocks have changed since.
+*/
+
+var moveLeftButton int
+var moveRightButton int
+
+type blockOrder struct {
+	order []int    // Provider indices in the order that they are sent
+	names []string // Provider names in their configured order, to notice when the blocks change
+}
+
+func newBlockOrder(blockProviders []blockProvider) *blockOrder {
+	result := &blockOrder{
+		order: make([]int, len(blockProviders)),
+		names: make([]string, len(blockProviders)),
+	}
+	for i, provider := range blockProviders {
+		result.order[i] = i
+		result.names[i] = provider.name()
+	}
+	return result
+}
+
+// Direction is -1 for left and 1 for right. Returns false if the block is already at that end.
+func (bo *blockOrder) move(providerIndex, direction int, isVisible func(providerIndex int) bool) bool {
+	position := -1
+	for i, index := range bo.order {
+		if index == providerIndex {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		return false
+	}
+
+	target := position + direction
+	for target >= 0 && target < len(bo.order) && !isVisible(bo.order[target]) {
+		target += direction
+	}
+	if target < 0 || target >= len(bo.order) {
+		return false
+	}
+
+	// Everything between the old and new position shifts over by one
+	if direction < 0 {
+		copy(bo.order[target+1:position+1], bo.order[target:position])
+	} else {
+		copy(bo.order[position:target], bo.order[position+1:target+1])
+	}
+	bo.order[target] = providerIndex
+
+	return true
+}
+
+// Returns the blocks in the current order, leaving the rendered blocks untouched
+func (bo *blockOrder) apply(fullBlockValues []fullSwaybarMessageBodyBlock) []fullSwaybarMessageBodyBlock {
+	result := make([]fullSwaybarMessageBodyBlock, len(fullBlockValues))
+	for i, index := range bo.order {
+		result[i] = fullBlockValues[index]
+	}
+	return result
+}
+
+type savedBlockOrder struct {
+	Order []int    `json:"order"`
+	Names []string `json:"names"`
+}
+
+func (bo *blockOrder) stateKey() string {
+	return "block order"
+}
+
+func (bo *blockOrder) MarshalState() ([]byte, error) {
+	return json.Marshal(savedBlockOrder{bo.order, bo.names})
+}
+
+func (bo *blockOrder) UnmarshalState(data []byte) error {
+	var saved savedBlockOrder
+	err := json.Unmarshal(data, &saved)
+	if err != nil {
+		return err
+	}
+
+	if len(saved.Names) != len(bo.names) || len(saved.Order) != len(bo.order) {
+		logger.Println("Blocks changed since the block order was saved, using the configured order")
+		return nil
+	}
+	for i, name := range saved.Names {
+		if name != bo.names[i] {
+			logger.Println("Blocks changed since the block order was saved, using the configured order")
+			return nil
+		}
+	}
+
+	// Has to be a permutation of the provider indices
+	seen := make([]bool, len(bo.order))
+	for _, index := range saved.Order {
+		if index < 0 || index >= len(seen) || seen[index] {
+			logger.Println("Ignoring invalid saved block order", saved.Order)
+			return nil
+		}
+		seen[index] = true
+	}
+
+	copy(bo.order, saved.Order)
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// UPower device types of the devices worth showing
+const (
+	upowerDeviceMouse       = 5
+	upowerDeviceKeyboard    = 6
+	upowerDeviceGamingInput = 12
+	upowerDeviceHeadset     = 17
+	upowerDeviceHeadphones  = 19
+)
+
+type peripheralBattery struct {
+	deviceType uint32
+	model      string
+	percent    int
+}
+
+var peripheralGlyphs = map[uint32]string{
+	upowerDeviceMouse:       "󰍽",
+	upowerDeviceKeyboard:    "",
+	upowerDeviceGamingInput: "󰊴",
+	upowerDeviceHeadset:     "󰋎",
+	upowerDeviceHeadphones:  "",
+}
+
+// Shows the wireless keyboard, mouse or other peripheral with the least charge left, as reported
+// by UPower. Logitech devices show up there through the hidpp kernel driver, Bluetooth devices
+// through BlueZ.
+type peripheralBatteryProvider struct {
+	lowest  peripheralBattery
+	present bool
+
+	lowPercent int // Urgent at or below this
+}
+
+func lowestPeripheralBattery(batteries []peripheralBattery) (peripheralBattery, bool) {
+	if len(batteries) == 0 {
+		return peripheralBattery{}, false
+	}
+
+	lowest := batteries[0]
+	for _, battery := range batteries[1:] {
+		if battery.percent < lowest.percent {
+			lowest = battery
+		}
+	}
+	return lowest, true
+}
+
+func (pb *peripheralBatteryProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	changes, err := watchPeripheralBatteries()
+	if err != nil {
+		logger.Println("Could not watch peripheral batteries, polling instead", err)
+	}
+
+	// Not every driver reports changes, so this polls as well
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		batteries, err := readPeripheralBatteries()
+		if err != nil {
+			logger.Println("Could not read peripheral batteries", err)
+		}
+
+		lowest, present := lowestPeripheralBattery(batteries)
+		if lowest != pb.lowest || present != pb.present {
+			pb.lowest, pb.present = lowest, present
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+
+		select {
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (pb *peripheralBatteryProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when no peripherals are connected
+	if !pb.present {
+		return block
+	}
+
+	glyph, exists := peripheralGlyphs[pb.lowest.deviceType]
+	if !exists {
+		glyph = pb.lowest.model
+	}
+	block.FullText = fmt.Sprintf("%s %d%%", glyph, pb.lowest.percent)
+	block.ShortText = glyph
+
+	if pb.lowest.percent <= pb.lowPercent {
+		urgent := true
+		block.Urgent = &urgent
+	}
+
+	return block
+}
+
+func (pb *peripheralBatteryProvider) name() string {
+	return ""
+}
+
+func (pb *peripheralBatteryProvider) respondToClick(event clickEvent) {}
@@ -0,0 +1,116 @@
+//go:build dbus
+
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const upowerService = "org.freedesktop.UPower"
+const upowerDevicesPath = dbus.ObjectPath("/org/freedesktop/UPower/devices")
+
+var peripheralDeviceTypes = map[uint32]bool{
+	upowerDeviceMouse:       true,
+	upowerDeviceKeyboard:    true,
+	upowerDeviceGamingInput: true,
+	upowerDeviceHeadset:     true,
+	upowerDeviceHeadphones:  true,
+}
+
+func readPeripheralBatteries() ([]peripheralBattery, error) {
+	system, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []dbus.ObjectPath
+	err = system.Object(upowerService, "/org/freedesktop/UPower").Call("org.freedesktop.UPower.EnumerateDevices", 0).Store(&devices)
+	if err != nil {
+		return nil, err
+	}
+
+	batteries := []peripheralBattery{}
+	for _, device := range devices {
+		var properties map[string]dbus.Variant
+		err := system.Object(upowerService, device).Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.UPower.Device").Store(&properties)
+		if err != nil {
+			continue // Removed in the meantime
+		}
+
+		deviceType, _ := properties["Type"].Value().(uint32)
+		powerSupply, _ := properties["PowerSupply"].Value().(bool)
+		isPresent, _ := properties["IsPresent"].Value().(bool)
+		percentage, _ := properties["Percentage"].Value().(float64)
+		model, _ := properties["Model"].Value().(string)
+
+		// The laptop's own battery is a power supply, peripherals only power themselves
+		if powerSupply || !isPresent || !peripheralDeviceTypes[deviceType] {
+			continue
+		}
+
+		batteries = append(batteries, peripheralBattery{
+			deviceType: deviceType,
+			model:      model,
+			percent:    int(percentage + 0.5),
+		})
+	}
+
+	return batteries, nil
+}
+
+// Sends when a device is added or removed or any device's properties change
+func watchPeripheralBatteries() (<-chan struct{}, error) {
+	system, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+
+	err = system.AddMatchSignal(
+		dbus.WithMatchPathNamespace(upowerDevicesPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range []string{"DeviceAdded", "DeviceRemoved"} {
+		err = system.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.UPower"),
+			dbus.WithMatchMember(member),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	system.Signal(signals)
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		// The connection is shared, so signals meant for other blocks arrive here too
+		for signal := range signals {
+			switch signal.Name {
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				if !signal.Path.IsValid() || !isUnder(signal.Path, upowerDevicesPath) {
+					continue
+				}
+			case "org.freedesktop.UPower.DeviceAdded", "org.freedesktop.UPower.DeviceRemoved":
+			default:
+				continue
+			}
+
+			select {
+			case changed <- struct{}{}:
+			default: // Already pending
+			}
+		}
+	}()
+
+	return changed, nil
+}
+
+func isUnder(path, parent dbus.ObjectPath) bool {
+	return len(path) > len(parent) && path[:len(parent)] == parent && path[len(parent)] == '/'
+}
@@ -0,0 +1,13 @@
+//go:build !dbus
+
+package main
+
+import "errors"
+
+func readPeripheralBatteries() ([]peripheralBattery, error) {
+	return nil, errors.New("built without DBus support, rebuild with -tags dbus")
+}
+
+func watchPeripheralBatteries() (<-chan struct{}, error) {
+	return nil, errors.New("built without DBus support, rebuild with -tags dbus")
+}
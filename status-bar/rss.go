@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tries to read a locked cache this many times before keeping the last count until the next poll
+const rssLockRetries = 3
+const rssLockRetryDelay = 2 * time.Second
+
+var errRSSCacheLocked = errors.New("newsboat cache is locked")
+
+// Counts unread newsboat articles
+type rssProvider struct {
+	commandProvider[int]
+
+	cacheFile    string        // Defaults to where newsboat keeps it
+	pollInterval time.Duration // Defaults to 15 minutes
+	reader       []string      // Run on click, the count is checked again once it exits
+}
+
+// newsboat uses ~/.newsboat if it exists and the XDG directories otherwise
+func newsboatCachePath() string {
+	home, _ := os.UserHomeDir()
+	if info, err := os.Stat(filepath.Join(home, ".newsboat")); err == nil && info.IsDir() {
+		return filepath.Join(home, ".newsboat", "cache.db")
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "newsboat", "cache.db")
+}
+
+// e.g. "12 unread articles"
+func parseNewsboatUnread(output []byte) (int, error) {
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected newsboat output %q", output)
+	}
+	return strconv.Atoi(fields[0])
+}
+
+// newsboat refuses to start a second instance, even with -x, so while it is open the cache is
+// read directly. sqlite3 waits up to its timeout for newsboat to finish writing.
+func readNewsboatUnread(cacheFile string) (int, error) {
+	output, err := commandOutput(0, "newsboat", "-c", cacheFile, "-x", "print-unread")
+
+	var exitError *exec.ExitError
+	if err == nil {
+		return parseNewsboatUnread(output)
+	} else if !errors.As(err, &exitError) || !strings.Contains(string(exitError.Stderr), "already running") {
+		return 0, err
+	}
+
+	output, err = commandOutput(0, "sqlite3", "-readonly", "-cmd", ".timeout 2000", cacheFile,
+		"SELECT COUNT(*) FROM rss_item WHERE unread = 1 AND deleted = 0;")
+	if errors.As(err, &exitError) && strings.Contains(string(exitError.Stderr), "locked") {
+		return 0, errRSSCacheLocked
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+func (rss *rssProvider) readUnread() (int, error) {
+	var err error
+	for attempt := 0; attempt < rssLockRetries; attempt++ {
+		var count int
+		count, err = readNewsboatUnread(rss.cacheFile)
+		if err != errRSSCacheLocked {
+			return count, err
+		}
+		time.Sleep(rssLockRetryDelay)
+	}
+	return 0, err
+}
+
+func (rss *rssProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if rss.cacheFile == "" {
+		rss.cacheFile = newsboatCachePath()
+	}
+
+	rss.fetch = rss.readUnread
+	rss.commandProvider.pollInterval = rss.pollInterval
+	if rss.pollInterval <= 0 {
+		rss.commandProvider.pollInterval = 15 * time.Minute
+	}
+
+	rss.commandProvider.monitor(changeChan, index)
+}
+
+func (rss *rssProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when everything has been read
+	if rss.value > 0 {
+		block.FullText = fmt.Sprintf(" %s", formatNumber(rss.value))
+		block.ShortText = ""
+	}
+
+	return block
+}
+
+func (rss *rssProvider) name() string {
+	return "rss"
+}
+
+func (rss *rssProvider) respondToClick(event clickEvent) {
+	if event.Button != 1 || len(rss.reader) == 0 {
+		return
+	}
+
+	err := exec.Command(rss.reader[0], rss.reader[1:]...).Run()
+	if err != nil {
+		logger.Println("Feed reader failed", err)
+	}
+	rss.requestRefresh()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"swayipc"
+)
+
+type scratchpadProvider struct {
+	count int
+}
+
+func countScratchpadWindows() (int, error) {
+	tree, err := getSwayTree()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	tree.walk(func(node, parent *swayNode) bool {
+		if node.Type == "workspace" && node.Name == "__i3_scratch" {
+			node.walk(func(child, _ *swayNode) bool {
+				if child.isWindow() {
+					count++
+				}
+				return true
+			})
+			return false
+		}
+		return true
+	})
+
+	return count, nil
+}
+
+func (sp *scratchpadProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	update := func() {
+		count, err := countScratchpadWindows()
+		if err != nil {
+			logger.Println("Could not read scratchpad", err)
+			return
+		}
+
+		if count != sp.count {
+			sp.count = count
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+
+	update()
+
+	subscription, err := swaySubscribe("window")
+	if err != nil {
+		logger.Println("Could not subscribe to window events", err)
+		return
+	}
+	defer subscription.Close()
+
+	for {
+		_, _, err := subscription.ReadEvent()
+		if err != nil {
+			logger.Println("Window event subscription closed", err)
+			return
+		}
+		update()
+	}
+}
+
+func (sp *scratchpadProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when there is nothing in the scratchpad
+	if sp.count > 0 {
+		block.FullText = fmt.Sprintf(" %d", sp.count)
+		block.ShortText = ""
+	}
+
+	return block
+}
+
+func (sp *scratchpadProvider) name() string {
+	return "scratchpad"
+}
+
+func (sp *scratchpadProvider) respondToClick(event clickEvent) {
+	if event.Button == 1 {
+		swayMsgCommand(swayipc.IPC_COMMAND, "scratchpad show")
+	}
+}
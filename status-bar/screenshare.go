@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+)
+
+// Shows a warning while the screen is being shared through xdg-desktop-portal, e.g. on a video call
+type screenShareProvider struct {
+	active bool
+}
+
+func (ss *screenShareProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	// Sessions are started by a reply that only the sharing app sees, so new sessions are polled for.
+	// Closing is signalled to everyone, so the warning goes away straight away.
+	sessionClosed, err := subscribePortalSessionClosed()
+	if err != nil {
+		logger.Println("Can't watch for screen sharing", err)
+		return
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		count, err := countPortalSessions()
+		if err != nil {
+			logger.Println("Could not read portal sessions", err)
+		}
+
+		active := count > 0
+		if active != ss.active {
+			ss.active = active
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-sessionClosed:
+		}
+	}
+}
+
+func (ss *screenShareProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	if ss.active {
+		block.FullText = " SHARING"
+		block.ShortText = ""
+		urgent := true
+		block.Urgent = &urgent
+	}
+
+	return block
+}
+
+func (ss *screenShareProvider) name() string {
+	return ""
+}
+
+func (ss *screenShareProvider) respondToClick(event clickEvent) {}
@@ -0,0 +1,70 @@
+//go:build dbus
+
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const portalSessionRoot = dbus.ObjectPath("/org/freedesktop/portal/desktop/session")
+
+// Portal sessions live at <portalSessionRoot>/<sender>/<token> until they are closed.
+// Screen casts and remote desktop sessions are the only common kinds of session.
+func countPortalSessions() (int, error) {
+	session, err := dbus.SessionBus()
+	if err != nil {
+		return 0, err
+	}
+
+	senders, err := introspect.Call(session.Object("org.freedesktop.portal.Desktop", portalSessionRoot))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, sender := range senders.Children {
+		path := dbus.ObjectPath(string(portalSessionRoot) + "/" + sender.Name)
+		tokens, err := introspect.Call(session.Object("org.freedesktop.portal.Desktop", path))
+		if err != nil {
+			continue
+		}
+		count += len(tokens.Children)
+	}
+
+	return count, nil
+}
+
+func subscribePortalSessionClosed() (<-chan struct{}, error) {
+	session, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	err = session.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.portal.Session"),
+		dbus.WithMatchMember("Closed"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	session.Signal(signals)
+
+	closed := make(chan struct{}, 1)
+	go func() {
+		for signal := range signals {
+			if signal.Name != "org.freedesktop.portal.Session.Closed" {
+				continue
+			}
+
+			select {
+			case closed <- struct{}{}:
+			default: // Already pending
+			}
+		}
+	}()
+
+	return closed, nil
+}
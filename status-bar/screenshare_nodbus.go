@@ -0,0 +1,13 @@
+//go:build !dbus
+
+package main
+
+import "errors"
+
+func countPortalSessions() (int, error) {
+	return 0, errors.New("built without DBus support, rebuild with -tags dbus")
+}
+
+func subscribePortalSessionClosed() (<-chan struct{}, error) {
+	return nil, errors.New("built without DBus support, rebuild with -tags dbus")
+}
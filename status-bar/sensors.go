@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chip, then label, then the value of the label's _input, e.g.
+//
+//	readings["coretemp-isa-0000"]["Package id 0"] = 45.0
+type sensorReadings map[string]map[string]float64
+
+// Parses the output of sensors -j, e.g.
+//
+//	{
+//	  "coretemp-isa-0000": {
+//	    "Adapter": "ISA adapter",
+//	    "Package id 0": {"temp1_input": 45.000, "temp1_max": 100.000, "temp1_crit": 100.000},
+//	    "Core 0": {"temp2_input": 42.000, "temp2_max": 100.000, "temp2_crit": 100.000}
+//	  }
+//	}
+func parseSensorsJSON(output []byte) (sensorReadings, error) {
+	var chips map[string]map[string]json.RawMessage
+	err := json.Unmarshal(output, &chips)
+	if err != nil {
+		return nil, err
+	}
+
+	readings := make(sensorReadings)
+	for chip, features := range chips {
+		chipReadings := make(map[string]float64)
+		for label, feature := range features {
+			// Skips "Adapter", which is a string
+			var subfeatures map[string]float64
+			if json.Unmarshal(feature, &subfeatures) != nil {
+				continue
+			}
+
+			for name, value := range subfeatures {
+				if strings.HasSuffix(name, "_input") {
+					chipReadings[label] = value
+					break
+				}
+			}
+		}
+		readings[chip] = chipReadings
+	}
+
+	return readings, nil
+}
+
+// Returns the reading of the first chip matching the pattern that has the label. The pattern is
+// matched like a file name, so the bus address can be left out, e.g. "coretemp-*". Chips are
+// checked in name order so that the same one is picked on every poll.
+func (readings sensorReadings) find(chipPattern, label string) (float64, bool) {
+	chips := make([]string, 0, len(readings))
+	for chip := range readings {
+		chips = append(chips, chip)
+	}
+	sort.Strings(chips)
+
+	for _, chip := range chips {
+		if matched, _ := filepath.Match(chipPattern, chip); !matched {
+			continue
+		}
+		if value, exists := readings[chip][label]; exists {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// Runs sensors -j once per interval for every block that shows a reading, e.g. temperatures and
+// fans, so that the readings of all of them are from the same moment and sensors isn't started
+// once per block. Polling starts with the first subscriber.
+type sensorsSource struct {
+	interval time.Duration // Defaults to one minute
+
+	lock        sync.Mutex
+	readings    sensorReadings
+	err         error
+	subscribers []chan struct{}
+	start       sync.Once
+}
+
+// The returned channel receives after every poll
+func (source *sensorsSource) subscribe() <-chan struct{} {
+	updates := make(chan struct{}, 1)
+
+	source.lock.Lock()
+	source.subscribers = append(source.subscribers, updates)
+	source.lock.Unlock()
+
+	source.start.Do(func() {
+		go source.poll()
+	})
+
+	return updates
+}
+
+func (source *sensorsSource) latest() (sensorReadings, error) {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	return source.readings, source.err
+}
+
+func (source *sensorsSource) poll() {
+	interval := source.interval
+	if interval <= 0 {
+		interval = 1 * time.Minute
+	}
+
+	for {
+		var readings sensorReadings
+		output, err := exec.Command("sensors", "-j").Output()
+		if err == nil {
+			readings, err = parseSensorsJSON(output)
+		}
+		if err != nil {
+			logger.Println("Could not read sensors", err)
+		}
+
+		source.lock.Lock()
+		source.readings, source.err = readings, err
+		for _, subscriber := range source.subscribers {
+			select {
+			case subscriber <- struct{}{}:
+			default: // Already pending
+			}
+		}
+		source.lock.Unlock()
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+type smartctlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+	} `json:"smartctl"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	NVMeHealth *struct {
+		PercentageUsed int `json:"percentage_used"`
+	} `json:"nvme_smart_health_information_log"`
+	ATAAttributes *struct {
+		Table []struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			Value      int    `json:"value"`
+			WhenFailed string `json:"when_failed"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+// Bits of smartctl's exit status that mean it couldn't read the device at all, e.g. without root
+const smartctlCouldNotRead = 0x1 | 0x2
+
+// SATA SSDs report wear as a normalized value that starts at 100 and counts down
+var ataWearAttributes = map[int]bool{
+	177: true, // Wear_Leveling_Count
+	231: true, // SSD_Life_Left
+	233: true, // Media_Wearout_Indicator
+}
+
+type smartHealth struct {
+	percentUsed int  // -1 when the drive doesn't report wear, e.g. a hard disk
+	failing     bool // The drive's overall assessment failed or an attribute is past its threshold
+}
+
+func readSmartHealth(device string) (smartHealth, error) {
+	health := smartHealth{percentUsed: -1}
+
+	output, err := exec.Command("smartctl", "--json", "-H", "-A", device).Output()
+	var exitError *exec.ExitError
+	if err != nil && !errors.As(err, &exitError) {
+		return health, err
+	}
+
+	// Other bits of the exit status describe the drive's health, which is reported in the JSON too
+	var result smartctlOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return health, err
+	}
+	if result.Smartctl.ExitStatus&smartctlCouldNotRead != 0 {
+		return health, fmt.Errorf("smartctl could not read %s, exit status %d", device, result.Smartctl.ExitStatus)
+	}
+
+	if result.SmartStatus != nil && !result.SmartStatus.Passed {
+		health.failing = true
+	}
+
+	if result.NVMeHealth != nil {
+		health.percentUsed = result.NVMeHealth.PercentageUsed
+	}
+
+	if result.ATAAttributes != nil {
+		for _, attribute := range result.ATAAttributes.Table {
+			if attribute.WhenFailed != "" {
+				health.failing = true
+			}
+			if ataWearAttributes[attribute.ID] && health.percentUsed < 0 {
+				health.percentUsed = 100 - attribute.Value
+			}
+		}
+	}
+
+	return health, nil
+}
+
+type smartProvider struct {
+	health smartHealth
+	valid  bool
+
+	device       string        // e.g. /dev/nvme0 or /dev/sda
+	pollInterval time.Duration // Defaults to 6 hours, wear changes over months
+}
+
+func (sp *smartProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if _, err := exec.LookPath("smartctl"); err != nil {
+		logger.Println("smartmontools is not installed, hiding disk health", err)
+		return
+	}
+
+	interval := sp.pollInterval
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+
+	for {
+		health, err := readSmartHealth(sp.device)
+		if err != nil {
+			// Reading SMART data usually needs root, which won't change while running
+			logger.Println("Could not read disk health, hiding it", err)
+			return
+		}
+
+		if !sp.valid || health != sp.health {
+			sp.health, sp.valid = health, true
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (sp *smartProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	if !sp.valid {
+		return block
+	}
+
+	if sp.health.percentUsed >= 0 {
+		block.FullText = fmt.Sprintf(" %d%%", sp.health.percentUsed)
+	} else {
+		block.FullText = " ok"
+	}
+
+	block.ShortText = ""
+
+	if sp.health.failing {
+		block.FullText = " failing"
+		urgent := true
+		block.Urgent = &urgent
+	}
+
+	return block
+}
+
+func (sp *smartProvider) name() string {
+	return ""
+}
+
+func (sp *smartProvider) respondToClick(event clickEvent) {}
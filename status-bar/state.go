@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Providers whose last value is worth showing after a restart, until they fetch a fresh one,
+// implement this. The key has to be unique and stay the same between versions.
+type persistentProvider interface {
+	stateKey() string
+	MarshalState() ([]byte, error)
+	UnmarshalState(data []byte) error
+}
+
+// Saved state is only used when this is set
+var persistState bool
+
+func blockStatePath() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "status-bar", "state.json"), nil
+}
+
+func persistentProviders(blockProviders []blockProvider) map[string]persistentProvider {
+	result := make(map[string]persistentProvider)
+	for _, provider := range blockProviders {
+		persistent, ok := provider.(persistentProvider)
+		if ok && persistent.stateKey() != "" {
+			result[persistent.stateKey()] = persistent
+		}
+	}
+	return result
+}
+
+// Has to run before the monitors start so that they overwrite the restored values
+func loadBlockState(providers map[string]persistentProvider) {
+	path, err := blockStatePath()
+	if err != nil {
+		logger.Println("Could not find block state", err)
+		return
+	}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		logger.Println("Could not read block state", err)
+		return
+	}
+
+	var state map[string]json.RawMessage
+	err = json.Unmarshal(contents, &state)
+	if err != nil {
+		logger.Println("Could not parse block state", err)
+		return
+	}
+
+	for key, provider := range providers {
+		data, exists := state[key]
+		if !exists {
+			continue
+		}
+
+		err = provider.UnmarshalState(data)
+		if err != nil {
+			logger.Println("Could not restore state of", key, err)
+		}
+	}
+}
+
+func saveBlockState(providers map[string]persistentProvider) {
+	path, err := blockStatePath()
+	if err != nil {
+		logger.Println("Could not find block state", err)
+		return
+	}
+
+	state := make(map[string]json.RawMessage)
+	for key, provider := range providers {
+		data, err := provider.MarshalState()
+		if err != nil {
+			logger.Println("Could not save state of", key, err)
+			continue
+		}
+		state[key] = data
+	}
+
+	contents, err := json.Marshal(state)
+	if err != nil {
+		logger.Println("Could not save block state", err)
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		logger.Println("Could not save block state", err)
+		return
+	}
+
+	// Written to a temporary file first so that being killed halfway doesn't leave a broken file
+	temporaryPath := path + ".tmp"
+	err = os.WriteFile(temporaryPath, contents, 0644)
+	if err == nil {
+		err = os.Rename(temporaryPath, path)
+	}
+	if err != nil {
+		logger.Println("Could not save block state", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"time"
+)
+
+// Counts what the bar does, for finding a block that updates too often or a goroutine leak. Off
+// by default, turned on with -collect-stats and read with status-bar -stats. Only used from the
+// main loop, so it needs no lock.
+var collectStats bool
+
+var stats barStats
+
+type blockStats struct {
+	changes     int // Change messages from the block's monitor
+	renders     int
+	lastError   string
+	lastErrorAt time.Time
+}
+
+type barStats struct {
+	started time.Time
+	blocks  []blockStats
+
+	displays     int
+	lastDisplay  time.Duration
+	maxDisplay   time.Duration
+	totalDisplay time.Duration
+}
+
+func (bs *barStats) block(index int) *blockStats {
+	for len(bs.blocks) <= index {
+		bs.blocks = append(bs.blocks, blockStats{})
+	}
+	return &bs.blocks[index]
+}
+
+func (bs *barStats) recordChange(index int) {
+	if collectStats {
+		bs.block(index).changes++
+	}
+}
+
+func (bs *barStats) recordRender(index int, err error) {
+	if !collectStats {
+		return
+	}
+
+	block := bs.block(index)
+	block.renders++
+	if err != nil {
+		block.lastError, block.lastErrorAt = err.Error(), time.Now()
+	}
+}
+
+// How long it took to render and send the bar
+func (bs *barStats) recordDisplay(duration time.Duration) {
+	if !collectStats {
+		return
+	}
+
+	bs.displays++
+	bs.lastDisplay = duration
+	bs.totalDisplay += duration
+	if duration > bs.maxDisplay {
+		bs.maxDisplay = duration
+	}
+}
+
+// A single line, so that it fits in a control socket reply
+func (bs *barStats) report(blockProviders []blockProvider) ([]byte, error) {
+	type blockReport struct {
+		Index       int        `json:"index"`
+		Name        string     `json:"name,omitempty"`
+		Changes     int        `json:"changes"`
+		Renders     int        `json:"renders"`
+		LastError   string     `json:"last_error,omitempty"`
+		LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	}
+
+	milliseconds := func(duration time.Duration) float64 {
+		return float64(duration) / float64(time.Millisecond)
+	}
+
+	report := struct {
+		Uptime            string        `json:"uptime"`
+		Goroutines        int           `json:"goroutines"`
+		Displays          int           `json:"displays"`
+		LastDisplayMillis float64       `json:"last_display_ms"`
+		MeanDisplayMillis float64       `json:"mean_display_ms"`
+		MaxDisplayMillis  float64       `json:"max_display_ms"`
+		Blocks            []blockReport `json:"blocks"`
+	}{
+		Uptime:            time.Since(bs.started).Round(time.Second).String(),
+		Goroutines:        runtime.NumGoroutine(),
+		Displays:          bs.displays,
+		LastDisplayMillis: milliseconds(bs.lastDisplay),
+		MaxDisplayMillis:  milliseconds(bs.maxDisplay),
+		Blocks:            make([]blockReport, len(blockProviders)),
+	}
+	if bs.displays > 0 {
+		report.MeanDisplayMillis = milliseconds(bs.totalDisplay / time.Duration(bs.displays))
+	}
+
+	for i, provider := range blockProviders {
+		block := bs.block(i)
+		report.Blocks[i] = blockReport{
+			Index:     i,
+			Name:      provider.name(),
+			Changes:   block.changes,
+			Renders:   block.renders,
+			LastError: block.lastError,
+		}
+		if block.lastError != "" {
+			lastErrorAt := block.lastErrorAt
+			report.Blocks[i].LastErrorAt = &lastErrorAt
+		}
+	}
+
+	return json.Marshal(report)
+}
@@ -0,0 +1,85 @@
+package main
+
+import "fmt"
+
+// Overrides for how a block is drawn, applied on top of whatever its provider renders. Set in
+// the block's section of config.toml, e.g. for an underline in an accent color:
+//
+//	[time]
+//	border-color = "#BD93F9"
+//	underline = 2
+//
+// Nil borders are left to swaybar's default of 1 pixel.
+type blockStyle struct {
+	BorderColor  string `toml:"border-color"` // #RRGGBB or #RRGGBBAA
+	BorderTop    *int   `toml:"border-top"`
+	BorderBottom *int   `toml:"border-bottom"`
+	BorderLeft   *int   `toml:"border-left"`
+	BorderRight  *int   `toml:"border-right"`
+	Underline    int    `toml:"underline"` // Only a bottom border this thick, instead of the sides above
+}
+
+// Only a bottom border, so that blocks look underlined
+func underlineStyle(borderColor string, thickness int) blockStyle {
+	zero := 0
+	return blockStyle{
+		BorderColor:  borderColor,
+		BorderTop:    &zero,
+		BorderBottom: &thickness,
+		BorderLeft:   &zero,
+		BorderRight:  &zero,
+	}
+}
+
+func (style blockStyle) validate() error {
+	if style.BorderColor != "" {
+		if _, ok := parseColor(style.BorderColor); !ok {
+			return fmt.Errorf("border-color %q isn't #RRGGBB or #RRGGBBAA", style.BorderColor)
+		}
+	}
+
+	sides := map[string]*int{
+		"border-top":    style.BorderTop,
+		"border-bottom": style.BorderBottom,
+		"border-left":   style.BorderLeft,
+		"border-right":  style.BorderRight,
+	}
+	for name, thickness := range sides {
+		if thickness == nil {
+			continue
+		}
+		if *thickness < 0 {
+			return fmt.Errorf("%s can't be negative", name)
+		}
+		if style.Underline != 0 {
+			return fmt.Errorf("underline sets every side, it can't be used with %s", name)
+		}
+	}
+
+	if style.Underline < 0 {
+		return fmt.Errorf("underline can't be negative")
+	}
+	return nil
+}
+
+func (style blockStyle) apply(block *fullSwaybarMessageBodyBlock) {
+	if style.Underline > 0 {
+		style = underlineStyle(style.BorderColor, style.Underline)
+	}
+
+	if style.BorderColor != "" {
+		block.Border = style.BorderColor
+	}
+	if style.BorderTop != nil {
+		block.BorderTop = style.BorderTop
+	}
+	if style.BorderBottom != nil {
+		block.BorderBottom = style.BorderBottom
+	}
+	if style.BorderLeft != nil {
+		block.BorderLeft = style.BorderLeft
+	}
+	if style.BorderRight != nil {
+		block.BorderRight = style.BorderRight
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func TestBlockStyleApply(t *testing.T) {
+	tests := []struct {
+		name                     string
+		style                    blockStyle
+		wantBorder               string
+		top, bottom, left, right *int
+	}{
+		{name: "nothing", style: blockStyle{}},
+		{
+			name:       "color only",
+			style:      blockStyle{BorderColor: "#BD93F9"},
+			wantBorder: "#BD93F9",
+		},
+		{
+			name:       "underline",
+			style:      blockStyle{BorderColor: "#BD93F9", Underline: 2},
+			wantBorder: "#BD93F9",
+			top:        intPointer(0), bottom: intPointer(2), left: intPointer(0), right: intPointer(0),
+		},
+		{
+			name:  "one side",
+			style: blockStyle{BorderLeft: intPointer(3)},
+			left:  intPointer(3),
+		},
+	}
+
+	sameWidth := func(got, want *int) bool {
+		if got == nil || want == nil {
+			return got == want
+		}
+		return *got == *want
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			block := fullSwaybarMessageBodyBlock{FullText: "a"}
+			test.style.apply(&block)
+
+			if block.Border != test.wantBorder {
+				t.Errorf("Border is %q, want %q", block.Border, test.wantBorder)
+			}
+			if !sameWidth(block.BorderTop, test.top) || !sameWidth(block.BorderBottom, test.bottom) ||
+				!sameWidth(block.BorderLeft, test.left) || !sameWidth(block.BorderRight, test.right) {
+				t.Errorf("Borders are %v %v %v %v", block.BorderTop, block.BorderBottom, block.BorderLeft, block.BorderRight)
+			}
+		})
+	}
+}
+
+func TestBlockStyleValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		style     blockStyle
+		wantError string
+	}{
+		{"empty", blockStyle{}, ""},
+		{"underline", blockStyle{BorderColor: "#BD93F9", Underline: 2}, ""},
+		{"sides", blockStyle{BorderColor: "#BD93F980", BorderTop: intPointer(0), BorderBottom: intPointer(2)}, ""},
+		{"bad color", blockStyle{BorderColor: "purple"}, "border-color"},
+		{"negative side", blockStyle{BorderRight: intPointer(-1)}, "border-right can't be negative"},
+		{"negative underline", blockStyle{Underline: -2}, "underline can't be negative"},
+		{"underline and a side", blockStyle{Underline: 2, BorderTop: intPointer(1)}, "can't be used with border-top"},
+	}
+
+	for _, test := range tests {
+		err := test.style.validate()
+		if test.wantError == "" {
+			if err != nil {
+				t.Errorf("%s: got error %v", test.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.wantError) {
+			t.Errorf("%s: got error %v, want %s", test.name, err, test.wantError)
+		}
+	}
+}
+
+// A block keeps its style when it fails to render, so that it stays in line with the others
+func TestUpdateSingleBlockAppliesStyle(t *testing.T) {
+	previousSettings := blockSettings
+	t.Cleanup(func() { blockSettings = previousSettings })
+	blockSettings = []BlockConfig{
+		{blockStyle: blockStyle{BorderColor: "#BD93F9", Underline: 2}},
+		{blockStyle: blockStyle{BorderColor: "#BD93F9", Underline: 2}},
+	}
+
+	providers := []blockProvider{
+		&fakeProvider{blockName: "working", block: fullSwaybarMessageBodyBlock{FullText: "a"}},
+		&failingProvider{fakeProvider{blockName: "broken"}},
+	}
+	blocks := make([]fullSwaybarMessageBodyBlock, len(providers))
+	updateFullBlockValues(blocks, providers)
+
+	for i, block := range blocks {
+		if block.Border != "#BD93F9" || block.BorderBottom == nil || *block.BorderBottom != 2 {
+			t.Errorf("Block %d isn't underlined: %+v", i, block)
+		}
+	}
+	if blocks[1].Color != errorBlockColor {
+		t.Error("The broken block isn't drawn as an error")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+
+	"swayipc"
+)
+
+// A connection per message, since the replies to messages sent from different goroutines could
+// otherwise be mixed up
+func swayMsgCommand(msgType int, payload string) ([]byte, error) {
+	var client swayipc.Client
+	err := client.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return client.Send(msgType, payload)
+}
+
+// Events are given by name, e.g. "window" or "workspace". Read them with ReadEvent.
+func swaySubscribe(events ...string) (*swayipc.Client, error) {
+	client := &swayipc.Client{}
+	err := client.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Subscribe(events...)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+type swayNode struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	Type           string     `json:"type"`
+	Layout         string     `json:"layout"`
+	Focused        bool       `json:"focused"`
+	FullscreenMode int        `json:"fullscreen_mode"`
+	Nodes          []swayNode `json:"nodes"`
+	FloatingNodes  []swayNode `json:"floating_nodes"`
+}
+
+func getSwayTree() (swayNode, error) {
+	var tree swayNode
+
+	jsonBytes, err := swayMsgCommand(swayipc.IPC_GET_TREE, "")
+	if err != nil {
+		return tree, err
+	}
+
+	err = json.Unmarshal(jsonBytes, &tree)
+	return tree, err
+}
+
+// Visits every node depth-first along with its parent. Returning false stops the walk.
+func (node *swayNode) walk(visit func(node, parent *swayNode) bool) bool {
+	for _, children := range [][]swayNode{node.Nodes, node.FloatingNodes} {
+		for i := range children {
+			child := &children[i]
+			if !visit(child, node) || !child.walk(visit) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (node *swayNode) isWindow() bool {
+	return (node.Type == "con" || node.Type == "floating_con") && len(node.Nodes) == 0 && len(node.FloatingNodes) == 0
+}
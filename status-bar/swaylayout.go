@@ -0,0 +1,128 @@
+package main
+
+import "swayipc"
+
+var layoutGlyphs = map[string]string{
+	"splith":  "",
+	"splitv":  "",
+	"tabbed":  "",
+	"stacked": "",
+}
+
+// The layout of the container that the focused window is in, or of the focused workspace when
+// it's empty. Empty when nothing is focused, e.g. while a layer surface has focus.
+func focusedLayout() (string, error) {
+	tree, err := getSwayTree()
+	if err != nil {
+		return "", err
+	}
+
+	layout := ""
+	tree.walk(func(node, parent *swayNode) bool {
+		if !node.Focused {
+			return true
+		}
+
+		if node.isWindow() {
+			layout = parent.Layout
+		} else {
+			layout = node.Layout
+		}
+		return false
+	})
+
+	return layout, nil
+}
+
+type swayLayoutProvider struct {
+	layout string
+
+	clicked chan struct{} // Layout commands sent over IPC don't cause an event
+}
+
+func (sl *swayLayoutProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	update := func() {
+		layout, err := focusedLayout()
+		if err != nil {
+			logger.Println("Could not read layout", err)
+			return
+		}
+
+		if layout != sl.layout {
+			sl.layout = layout
+			changeChan <- blockChangedMessage{
+				index: index,
+			}
+		}
+	}
+
+	update()
+
+	// There is no layout event. Changing the layout with a key binding sends a binding event and
+	// focusing another container sends a window or workspace event. Focus also moves when the
+	// focused output is unplugged, which can come with only an output event.
+	subscription, err := swaySubscribe("window", "workspace", "binding", "output")
+	if err != nil {
+		logger.Println("Could not subscribe to window events", err)
+		return
+	}
+	defer subscription.Close()
+
+	events := make(chan struct{})
+	go func() {
+		defer close(events)
+		for {
+			_, _, err := subscription.ReadEvent()
+			if err != nil {
+				logger.Println("Window event subscription closed", err)
+				return
+			}
+			events <- struct{}{}
+		}
+	}()
+
+	for {
+		select {
+		case _, isOpen := <-events:
+			if !isOpen {
+				return
+			}
+		case <-sl.clicked:
+		}
+		update()
+	}
+}
+
+func (sl *swayLayoutProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when the layout can't be worked out
+	if glyph, exists := layoutGlyphs[sl.layout]; exists {
+		block.FullText = glyph + " " + sl.layout
+		block.ShortText = glyph
+	}
+
+	return block
+}
+
+func (sl *swayLayoutProvider) name() string {
+	return "layout"
+}
+
+// Cycles through splith, splitv, stacked and tabbed
+func (sl *swayLayoutProvider) respondToClick(event clickEvent) {
+	if event.Button != 1 {
+		return
+	}
+
+	_, err := swayMsgCommand(swayipc.IPC_COMMAND, "layout toggle all")
+	if err != nil {
+		logger.Println("Could not change layout", err)
+		return
+	}
+
+	select {
+	case sl.clicked <- struct{}{}:
+	default: // Already pending
+	}
+}
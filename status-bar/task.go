@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Counts pending tasks from taskwarrior, or from a todo.txt file when todoFile is set
+type taskProvider struct {
+	refreshTrigger
+
+	pending int
+	overdue int
+
+	todoFile     string        // Watched for changes instead of polling taskwarrior
+	pollInterval time.Duration // Only used for taskwarrior
+	manager      []string      // Run on click
+}
+
+func taskwarriorCount(filter string) (int, error) {
+	output, err := exec.Command("task", "rc.gc=off", filter, "count").Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+func readTaskwarrior() (pending int, overdue int, err error) {
+	pending, err = taskwarriorCount("+PENDING")
+	if err != nil {
+		return 0, 0, err
+	}
+	overdue, err = taskwarriorCount("+OVERDUE")
+	return pending, overdue, err
+}
+
+// Lines that start with "x " are done. Tasks with a due:YYYY-MM-DD tag before today are overdue.
+func readTodoTxt(path string, today time.Time) (pending int, overdue int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "x ") {
+			continue
+		}
+		pending++
+
+		for _, field := range strings.Fields(line) {
+			dueString, found := strings.CutPrefix(field, "due:")
+			if !found {
+				continue
+			}
+			due, err := time.ParseInLocation("2006-01-02", dueString, time.Local)
+			if err == nil && due.Before(today) {
+				overdue++
+			}
+			break
+		}
+	}
+
+	return pending, overdue, scanner.Err()
+}
+
+func (tp *taskProvider) update(changeChan chan<- blockChangedMessage, index int) {
+	var pending, overdue int
+	var err error
+	if tp.todoFile != "" {
+		pending, overdue, err = readTodoTxt(tp.todoFile, time.Now())
+	} else {
+		pending, overdue, err = readTaskwarrior()
+	}
+
+	if err != nil {
+		logger.Println("Could not count tasks", err)
+		return
+	}
+
+	if pending != tp.pending || overdue != tp.overdue {
+		tp.pending, tp.overdue = pending, overdue
+		changeChan <- blockChangedMessage{
+			index: index,
+		}
+	}
+}
+
+func (tp *taskProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if tp.todoFile != "" {
+		tp.update(changeChan, index)
+
+		changes, err := watchPath(tp.todoFile, 200*time.Millisecond)
+		if err != nil {
+			logger.Println("Could not watch", tp.todoFile, err)
+			return
+		}
+
+		// Due dates pass without the file changing, so it is also re-read every hour
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-changes:
+			case <-ticker.C:
+			case <-tp.refreshRequests():
+			}
+			tp.update(changeChan, index)
+		}
+	}
+
+	if _, err := exec.LookPath("task"); err != nil {
+		logger.Println("taskwarrior is not installed, hiding tasks", err)
+		return
+	}
+
+	interval := tp.pollInterval
+	if interval <= 0 {
+		interval = 1 * time.Minute
+	}
+
+	for {
+		tp.update(changeChan, index)
+
+		select {
+		case <-time.After(interval):
+		case <-tp.refreshRequests():
+		}
+	}
+}
+
+func (tp *taskProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when there is nothing to do
+	if tp.pending > 0 {
+		block.FullText = fmt.Sprintf(" %s", formatNumber(tp.pending))
+		block.ShortText = ""
+		if tp.overdue > 0 {
+			urgent := true
+			block.Urgent = &urgent
+		}
+	}
+
+	return block
+}
+
+func (tp *taskProvider) name() string {
+	return "tasks"
+}
+
+func (tp *taskProvider) respondToClick(event clickEvent) {
+	if event.Button == 1 && len(tp.manager) > 0 {
+		launchDetached(tp.manager[0], tp.manager[1:]...)
+	}
+}
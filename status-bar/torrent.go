@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Which torrent client torrentProvider asks
+type torrentClient string
+
+const (
+	transmissionClient torrentClient = "transmission" // transmission-remote -l
+	qbittorrentClient  torrentClient = "qbittorrent"  // The Web API, which must allow localhost without a login
+)
+
+type torrentStatus struct {
+	online   bool
+	active   int     // Torrents that are sending or receiving
+	download float64 // Bytes per second
+	upload   float64 // Bytes per second
+}
+
+// Shows how many torrents are active and how fast they are transferring in total
+type torrentProvider struct {
+	commandProvider[torrentStatus]
+
+	client       torrentClient // Defaults to transmission
+	webUIURL     string        // For qBittorrent, defaults to http://localhost:8080
+	pollInterval time.Duration // Defaults to 10 seconds
+	manager      []string      // Run on click
+	hideWhenIdle bool
+}
+
+// Parses the Up and Down columns, which are in kB/s, e.g.
+//
+//	    ID   Done       Have  ETA           Up    Down  Ratio  Status       Name
+//	     1   100%   1.23 GB  Done         12.0     0.0    1.2  Seeding      debian.iso
+//	     2*   45%  500.0 MB  10 min        0.0   345.0    0.0  Downloading  arch.iso
+//	Sum:          1.73 GB               12.0   345.0
+//
+// The columns are right aligned under their headers and ETA can have a space in it, so the
+// values are found by where the headers end.
+func parseTransmissionList(output []byte) (torrentStatus, error) {
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	header := lines[0]
+	upStart, downStart := strings.Index(header, " Up "), strings.Index(header, " Down ")
+	if upStart < 0 || downStart < 0 {
+		return torrentStatus{}, fmt.Errorf("unexpected transmission-remote header %q", header)
+	}
+	upEnd, downEnd := upStart+len(" Up"), downStart+len(" Down")
+
+	status := torrentStatus{online: true}
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "Sum:") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return torrentStatus{}, fmt.Errorf("unexpected transmission-remote total %q", line)
+			}
+
+			up, upErr := strconv.ParseFloat(fields[len(fields)-2], 64)
+			down, downErr := strconv.ParseFloat(fields[len(fields)-1], 64)
+			if upErr != nil || downErr != nil {
+				return torrentStatus{}, fmt.Errorf("unexpected transmission-remote total %q", line)
+			}
+			status.upload, status.download = up*1000, down*1000
+			continue
+		}
+
+		if len(line) < downEnd {
+			continue
+		}
+
+		upFields := strings.Fields(line[:upEnd])
+		if len(upFields) == 0 {
+			continue
+		}
+
+		up, upErr := strconv.ParseFloat(upFields[len(upFields)-1], 64)
+		down, downErr := strconv.ParseFloat(strings.TrimSpace(line[upEnd:downEnd]), 64)
+		if upErr == nil && downErr == nil && (up > 0 || down > 0) {
+			status.active++
+		}
+	}
+
+	return status, nil
+}
+
+func fetchQBittorrentJSON(url string, value any) error {
+	client := http.Client{Timeout: defaultCommandTimeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusForbidden {
+		return errors.New("qBittorrent wants a login, allow localhost to skip it in the Web UI settings")
+	} else if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("qBittorrent replied %s", response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(value)
+}
+
+func readQBittorrent(webUIURL string) (torrentStatus, error) {
+	var transfer struct {
+		Download float64 `json:"dl_info_speed"`
+		Upload   float64 `json:"up_info_speed"`
+	}
+	err := fetchQBittorrentJSON(webUIURL+"/api/v2/transfer/info", &transfer)
+	if err != nil {
+		return torrentStatus{}, err
+	}
+
+	var active []json.RawMessage
+	err = fetchQBittorrentJSON(webUIURL+"/api/v2/torrents/info?filter=active", &active)
+	if err != nil {
+		return torrentStatus{}, err
+	}
+
+	return torrentStatus{
+		online:   true,
+		active:   len(active),
+		download: transfer.Download,
+		upload:   transfer.Upload,
+	}, nil
+}
+
+// A client that isn't running isn't an error, the block is hidden until it is back
+func (tp *torrentProvider) readStatus() (torrentStatus, error) {
+	var status torrentStatus
+	var err error
+	if tp.client == qbittorrentClient {
+		status, err = readQBittorrent(tp.webUIURL)
+	} else {
+		status, err = tp.runCommand()
+	}
+
+	if err != nil {
+		if tp.value.online || !tp.valid {
+			logger.Println("Torrent client unreachable", tp.client, err)
+		}
+		return torrentStatus{}, nil
+	}
+	return status, nil
+}
+
+func (tp *torrentProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if tp.client == "" {
+		tp.client = transmissionClient
+	}
+	if tp.webUIURL == "" {
+		tp.webUIURL = "http://localhost:8080"
+	}
+	tp.webUIURL = strings.TrimSuffix(tp.webUIURL, "/")
+
+	tp.command = []string{"transmission-remote", "-l"}
+	tp.parse = parseTransmissionList
+	tp.fetch = tp.readStatus
+	tp.commandProvider.pollInterval = tp.pollInterval
+	if tp.pollInterval <= 0 {
+		tp.commandProvider.pollInterval = 10 * time.Second
+	}
+
+	tp.commandProvider.monitor(changeChan, index)
+}
+
+func (tp *torrentProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden while the client isn't running
+	status := tp.value
+	if !status.online || (tp.hideWhenIdle && status.active == 0) {
+		return block
+	}
+
+	rates := ""
+	if status.active > 0 {
+		rates = joinSegments(segmentSeparator, "↓"+formatByteRate(status.download), "↑"+formatByteRate(status.upload))
+	}
+
+	block.FullText = joinSegments(" ", "", strconv.Itoa(status.active), rates)
+	block.ShortText = ""
+
+	return block
+}
+
+func (tp *torrentProvider) name() string {
+	return "torrents"
+}
+
+func (tp *torrentProvider) respondToClick(event clickEvent) {
+	if event.Button == 1 && len(tp.manager) > 0 {
+		launchDetached(tp.manager[0], tp.manager[1:]...)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Counts pending package updates on Arch with checkupdates, which uses a separate copy of the
+// sync database so that nothing is half upgraded
+type updatesProvider struct {
+	refreshTrigger
+
+	count int
+
+	includeAUR     bool          // Also count AUR updates with paru -Qua
+	urgentCount    int           // Urgent when more than this many updates are pending, 0 to never be urgent
+	pollInterval   time.Duration // Defaults to an hour since checking downloads the package databases
+	upgradeCommand []string      // Run on click, the count is checked again once it exits
+}
+
+// Counts the lines of output. Both commands exit with an error when there is nothing to update
+// (checkupdates with 2, paru with 1), which isn't a failure.
+func countOutputLines(name string, args ...string) (int, error) {
+	output, err := exec.Command(name, args...).Output()
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) && len(strings.TrimSpace(string(output))) == 0 && len(exitError.Stderr) == 0 {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (up *updatesProvider) update(changeChan chan<- blockChangedMessage, index int) {
+	count, err := countOutputLines("checkupdates")
+	if err != nil {
+		logger.Println("Could not check for updates", err)
+		return
+	}
+
+	if up.includeAUR {
+		aurCount, err := countOutputLines("paru", "-Qua")
+		if err != nil {
+			// Soft error, the official repositories were still counted
+			logger.Println("Could not check for AUR updates", err)
+		}
+		count += aurCount
+	}
+
+	if count != up.count {
+		up.count = count
+		changeChan <- blockChangedMessage{
+			index: index,
+		}
+	}
+}
+
+func (up *updatesProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	if _, err := exec.LookPath("checkupdates"); err != nil {
+		logger.Println("checkupdates is not installed, hiding updates", err)
+		return
+	}
+
+	interval := up.pollInterval
+	if interval <= 0 {
+		interval = 1 * time.Hour
+	}
+
+	for {
+		up.update(changeChan, index)
+
+		select {
+		case <-time.After(interval):
+		case <-up.refreshRequests():
+		}
+	}
+}
+
+func (up *updatesProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden when everything is up to date
+	if up.count > 0 {
+		block.FullText = fmt.Sprintf(" %s", formatNumber(up.count))
+		block.ShortText = ""
+		if up.urgentCount > 0 && up.count > up.urgentCount {
+			urgent := true
+			block.Urgent = &urgent
+		}
+	}
+
+	return block
+}
+
+func (up *updatesProvider) name() string {
+	return "updates"
+}
+
+func (up *updatesProvider) respondToClick(event clickEvent) {
+	if event.Button != 1 || len(up.upgradeCommand) == 0 {
+		return
+	}
+
+	err := exec.Command(up.upgradeCommand[0], up.upgradeCommand[1:]...).Run()
+	if err != nil {
+		logger.Println("Upgrade command failed", err)
+	}
+	up.requestRefresh()
+}
+
+// The count is kept across restarts because checking takes a while
+func (up *updatesProvider) stateKey() string {
+	return "updates"
+}
+
+func (up *updatesProvider) MarshalState() ([]byte, error) {
+	return json.Marshal(up.count)
+}
+
+func (up *updatesProvider) UnmarshalState(data []byte) error {
+	return json.Unmarshal(data, &up.count)
+}
@@ -3,6 +3,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 )
 
 type borderThickness struct {
@@ -12,10 +16,201 @@ type borderThickness struct {
 	Right  int
 }
 
+// Red in the lowest byte, then green, then blue, i.e. 0xBBGGRR. Anything above the blue byte,
+// like an alpha, is ignored.
 type color int
 
+// e.g. color(0xFF) is "#FF0000"
 func colorToString(c color) string {
-	return fmt.Sprintf("#%02d%02d%02d", c&0xFF, (c>>8)&0xFF, (c>>16)&0xFF)
+	return fmt.Sprintf("#%02X%02X%02X", c&0xFF, (c>>8)&0xFF, (c>>16)&0xFF)
+}
+
+// Parses #RRGGBB or #RRGGBBAA into a color. The alpha is ignored.
+func parseColor(hex string) (color, bool) {
+	var r, g, b uint8
+	trimmed := strings.TrimPrefix(hex, "#")
+	if len(trimmed) != 6 && len(trimmed) != 8 {
+		return 0, false
+	}
+	_, err := fmt.Sscanf(trimmed[:6], "%02x%02x%02x", &r, &g, &b)
+	if err != nil {
+		return 0, false
+	}
+	return color(r) | color(g)<<8 | color(b)<<16, true
+}
+
+// WCAG relative luminance, from 0 for black to 1 for white
+func relativeLuminance(c color) float64 {
+	linear := func(channel color) float64 {
+		value := float64(channel&0xFF) / 255
+		if value <= 0.03928 {
+			return value / 12.92
+		}
+		return math.Pow((value+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c) + 0.7152*linear(c>>8) + 0.0722*linear(c>>16)
+}
+
+// Above this luminance black text has more contrast than white text
+const contrastThreshold = 0.179
+
+// Black or white, whichever is easier to read on the background
+func contrastingTextColor(background color) string {
+	if relativeLuminance(background) > contrastThreshold {
+		return "#000000"
+	}
+	return "#FFFFFF"
+}
+
+type temperatureUnit int
+
+const (
+	unitCelsius temperatureUnit = iota
+	unitFahrenheit
+)
+
+// Accepts the names used in config.toml, celsius and fahrenheit, or just C and F
+func parseTemperatureUnit(value string) (temperatureUnit, error) {
+	switch strings.ToLower(value) {
+	case "celsius", "c":
+		return unitCelsius, nil
+	case "fahrenheit", "f":
+		return unitFahrenheit, nil
+	}
+	return 0, fmt.Errorf("unknown temperature unit %q, options are celsius and fahrenheit", value)
+}
+
+func celsiusToFahrenheit(degrees float64) float64 {
+	return degrees*9/5 + 32
+}
+
+// Rounds to whole degrees, e.g. "45°C"
+func formatTemperature(degreesCelsius float64, unit temperatureUnit) string {
+	if unit == unitFahrenheit {
+		return fmt.Sprintf("%.0f°F", celsiusToFahrenheit(degreesCelsius))
+	}
+	return fmt.Sprintf("%.0f°C", degreesCelsius)
+}
+
+// Thousands separators by language, or language and territory where they differ
+var thousandsSeparators = map[string]string{
+	"en": ",", "ja": ",", "zh": ",", "ko": ",", "he": ",",
+	"de": ".", "nl": ".", "it": ".", "es": ".", "pt": ".", "da": ".", "id": ".", "tr": ".", "el": ".", "ro": ".",
+	"fr": "\u202f", "ru": "\u00a0", "uk": "\u00a0", "pl": "\u00a0", "cs": "\u00a0", "sk": "\u00a0",
+	"sv": "\u00a0", "fi": "\u00a0", "nb": "\u00a0", "no": "\u00a0", "bg": "\u00a0",
+	"de_CH": "'", "it_CH": "'", "pt_BR": ".", "es_MX": ",",
+}
+
+// The locale that numbers are formatted in, following the same precedence as setlocale
+func numericLocale() string {
+	for _, variable := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if value := os.Getenv(variable); value != "" {
+			return value
+		}
+	}
+	return "C"
+}
+
+func thousandsSeparator(locale string) string {
+	// e.g. de_DE.UTF-8@euro
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+
+	if separator, exists := thousandsSeparators[locale]; exists {
+		return separator
+	}
+	language, _, _ := strings.Cut(locale, "_")
+	return thousandsSeparators[language] // C and POSIX don't group digits
+}
+
+// Groups the digits of large numbers in the user's locale, e.g. 1234567 is "1,234,567" in en_US
+// and "1.234.567" in de_DE. Numbers are left as they are for unknown locales.
+func formatNumber(number int) string {
+	digits := strconv.Itoa(number)
+	separator := thousandsSeparator(numericLocale())
+	if separator == "" {
+		return digits
+	}
+
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+
+	var result strings.Builder
+	result.WriteString(sign)
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			result.WriteString(separator)
+		}
+		result.WriteRune(digit)
+	}
+	return result.String()
+}
+
+// e.g. "512 B/s", "1.5 kB/s" or "12 MB/s", with one decimal below 10
+func formatByteRate(bytesPerSecond float64) string {
+	units := []string{"B/s", "kB/s", "MB/s", "GB/s"}
+	unit := 0
+	for bytesPerSecond >= 1000 && unit < len(units)-1 {
+		bytesPerSecond /= 1000
+		unit++
+	}
+
+	if unit > 0 && bytesPerSecond < 10 {
+		return fmt.Sprintf("%.1f %s", bytesPerSecond, units[unit])
+	}
+	return fmt.Sprintf("%.0f %s", bytesPerSecond, units[unit])
+}
+
+// Picks the glyph for a percentage from glyphs ordered from lowest to highest, each covering an
+// equal share of 0 to 100, e.g. with four glyphs the second is for 25 up to but not including 50.
+// Values outside of 0 to 100 get the first or last glyph and an empty ramp gives "".
+func rampGlyph(value float64, glyphs []string) string {
+	if len(glyphs) == 0 {
+		return ""
+	}
+
+	// Also catches NaN
+	if !(value > 0) {
+		return glyphs[0]
+	}
+	// Before the conversion to int, which overflows for infinity
+	if value >= 100 {
+		return glyphs[len(glyphs)-1]
+	}
+
+	return glyphs[int(value/100*float64(len(glyphs)))]
+}
+
+// Goes between the separate values of blocks that show more than one, e.g. the left and right
+// volume. An icon and its value are not separate values and always have a single space.
+var segmentSeparator = " "
+
+// Empty parts are skipped so that optional values don't leave a doubled separator behind
+func joinSegments(separator string, parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, separator)
+}
+
+// Cuts s down to at most max characters, the last of which is "…" if anything was cut.
+// Counts runes rather than bytes so that multibyte characters aren't split. A max of 0 or
+// less means there is no limit.
+func truncate(s string, max int) string {
+	if max <= 0 {
+		return s
+	}
+
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
 }
 
 type swaybarMessageBody []swaybarMessageBodyBlock
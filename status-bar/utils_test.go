@@ -0,0 +1,301 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello world", 5, "hell…"},
+		{"hello", 1, "…"},
+		{"hello", 0, "hello"}, // No limit
+		{"hello", -1, "hello"},
+		{"", 3, ""},
+		{"Zürich", 6, "Zürich"},        // 7 bytes but 6 characters
+		{"Zürich und Genf", 4, "Zür…"}, // Cut right after the ü
+		{"日本語のテキスト", 4, "日本語…"},
+		{"🌧 Rain", 2, "🌧…"},
+	}
+
+	for _, test := range tests {
+		got := truncate(test.s, test.max)
+		if got != test.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", test.s, test.max, got, test.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) split a character: %q", test.s, test.max, got)
+		}
+	}
+}
+
+func TestThousandsSeparator(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en_US.UTF-8", ","},
+		{"de_DE.UTF-8@euro", "."},
+		{"de_CH.UTF-8", "'"}, // The territory wins over the language
+		{"fr_FR.UTF-8", "\u202f"},
+		{"ru_RU", "\u00a0"},
+		{"pt_BR.UTF-8", "."},
+		{"C", ""},
+		{"POSIX", ""},
+		{"xx_YY.UTF-8", ""}, // Unknown
+	}
+
+	for _, test := range tests {
+		if got := thousandsSeparator(test.locale); got != test.want {
+			t.Errorf("thousandsSeparator(%q) = %q, want %q", test.locale, got, test.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		locale string
+		number int
+		want   string
+	}{
+		{"en_US.UTF-8", 0, "0"},
+		{"en_US.UTF-8", 999, "999"},
+		{"en_US.UTF-8", 1000, "1,000"},
+		{"en_US.UTF-8", 1234567, "1,234,567"},
+		{"en_US.UTF-8", -1234567, "-1,234,567"},
+		{"en_US.UTF-8", -123, "-123"}, // No separator after the sign
+		{"de_DE.UTF-8", 1234567, "1.234.567"},
+		{"de_CH.UTF-8", 100000, "100'000"},
+		{"fr_FR.UTF-8", 12345, "12\u202f345"},
+		{"C", 1234567, "1234567"},
+	}
+
+	for _, test := range tests {
+		t.Setenv("LC_ALL", test.locale)
+		if got := formatNumber(test.number); got != test.want {
+			t.Errorf("formatNumber(%d) in %s = %q, want %q", test.number, test.locale, got, test.want)
+		}
+	}
+}
+
+// LC_ALL overrides LC_NUMERIC, which overrides LANG
+func TestNumericLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_NUMERIC", "")
+	t.Setenv("LANG", "")
+	if got := numericLocale(); got != "C" {
+		t.Errorf("Without any variables the locale is %q, want C", got)
+	}
+
+	t.Setenv("LANG", "en_US.UTF-8")
+	t.Setenv("LC_NUMERIC", "de_DE.UTF-8")
+	if got := numericLocale(); got != "de_DE.UTF-8" {
+		t.Errorf("LC_NUMERIC gave %q, want de_DE.UTF-8", got)
+	}
+
+	t.Setenv("LC_ALL", "fr_FR.UTF-8")
+	if got := numericLocale(); got != "fr_FR.UTF-8" {
+		t.Errorf("LC_ALL gave %q, want fr_FR.UTF-8", got)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		hex    string
+		want   color
+		wantOk bool
+	}{
+		{"#FF0000", 0x0000FF, true},
+		{"#00FF00", 0x00FF00, true},
+		{"#0000FF", 0xFF0000, true},
+		{"#123456", 0x563412, true},
+		{"#abcdef", 0xEFCDAB, true},
+		{"123456", 0x563412, true},
+		{"#12345680", 0x563412, true}, // The alpha is ignored
+		{"#12345", 0, false},
+		{"#1234567", 0, false},
+		{"#GG0000", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseColor(test.hex)
+		if got != test.want || ok != test.wantOk {
+			t.Errorf("parseColor(%q) = %#06x, %v, want %#06x, %v", test.hex, int(got), ok, int(test.want), test.wantOk)
+		}
+	}
+}
+
+func TestColorToString(t *testing.T) {
+	tests := []struct {
+		c    color
+		want string
+	}{
+		{0x000000, "#000000"},
+		{0xFFFFFF, "#FFFFFF"},
+		{0x0000FF, "#FF0000"},
+		{0x00FF00, "#00FF00"},
+		{0xFF0000, "#0000FF"},
+		{0x563412, "#123456"},
+		{0x80FF0000, "#0000FF"}, // Half transparent blue, the alpha is dropped
+		{color(255), "#FF0000"},
+	}
+
+	for _, test := range tests {
+		if got := colorToString(test.c); got != test.want {
+			t.Errorf("colorToString(%#x) = %q, want %q", int(test.c), got, test.want)
+		}
+	}
+}
+
+func TestRelativeLuminance(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want float64
+	}{
+		{"#000000", 0},
+		{"#FFFFFF", 1},
+		{"#FF0000", 0.2126},
+		{"#00FF00", 0.7152},
+		{"#0000FF", 0.0722},
+		{"#808080", 0.2159},
+	}
+
+	for _, test := range tests {
+		parsed, _ := parseColor(test.hex)
+		if got := relativeLuminance(parsed); math.Abs(got-test.want) > 0.001 {
+			t.Errorf("relativeLuminance(%s) = %.4f, want %.4f", test.hex, got, test.want)
+		}
+	}
+}
+
+func TestContrastingTextColor(t *testing.T) {
+	tests := []struct {
+		background string
+		want       string
+	}{
+		{"#000000", "#FFFFFF"},
+		{"#FFFFFF", "#000000"},
+		{"#FF5555", "#000000"}, // The urgent background
+		{"#FFFF00", "#000000"},
+		{"#00FF00", "#000000"},
+		{"#0000FF", "#FFFFFF"},
+		{"#000080", "#FFFFFF"},
+		{"#282A36", "#FFFFFF"},
+		// Either side of the threshold
+		{"#777777", "#000000"},
+		{"#707070", "#FFFFFF"},
+	}
+
+	for _, test := range tests {
+		parsed, _ := parseColor(test.background)
+		if got := contrastingTextColor(parsed); got != test.want {
+			t.Errorf("contrastingTextColor(%s) = %s, want %s", test.background, got, test.want)
+		}
+	}
+}
+
+func TestRampGlyph(t *testing.T) {
+	ramp := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "a"},
+		{24.9, "a"},
+		{25, "b"},
+		{49.9, "b"},
+		{50, "c"},
+		{75, "d"},
+		{99.9, "d"},
+		{100, "d"},
+		{-10, "a"},
+		{250, "d"},
+		{math.NaN(), "a"},
+		{math.Inf(1), "d"},
+		{math.Inf(-1), "a"},
+	}
+
+	for _, test := range tests {
+		if got := rampGlyph(test.value, ramp); got != test.want {
+			t.Errorf("rampGlyph(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+
+	if got := rampGlyph(50, nil); got != "" {
+		t.Errorf("An empty ramp gave %q", got)
+	}
+	if got := rampGlyph(50, []string{"only"}); got != "only" {
+		t.Errorf("A ramp of one gave %q", got)
+	}
+}
+
+func TestCelsiusToFahrenheit(t *testing.T) {
+	tests := []struct {
+		celsius, want float64
+	}{
+		{0, 32},
+		{100, 212},
+		{-40, -40},
+		{37, 98.6},
+		{-273.15, -459.67},
+	}
+
+	for _, test := range tests {
+		if got := celsiusToFahrenheit(test.celsius); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("celsiusToFahrenheit(%v) = %v, want %v", test.celsius, got, test.want)
+		}
+	}
+}
+
+func TestFormatTemperature(t *testing.T) {
+	tests := []struct {
+		celsius float64
+		unit    temperatureUnit
+		want    string
+	}{
+		{45, unitCelsius, "45°C"},
+		{45.4, unitCelsius, "45°C"},
+		{45.6, unitCelsius, "46°C"},
+		{-3.2, unitCelsius, "-3°C"},
+		{0, unitFahrenheit, "32°F"},
+		{22, unitFahrenheit, "72°F"},
+		{-40, unitFahrenheit, "-40°F"},
+	}
+
+	for _, test := range tests {
+		if got := formatTemperature(test.celsius, test.unit); got != test.want {
+			t.Errorf("formatTemperature(%v, %v) = %q, want %q", test.celsius, test.unit, got, test.want)
+		}
+	}
+}
+
+func TestParseTemperatureUnit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    temperatureUnit
+		wantErr bool
+	}{
+		{"celsius", unitCelsius, false},
+		{"Celsius", unitCelsius, false},
+		{"C", unitCelsius, false},
+		{"fahrenheit", unitFahrenheit, false},
+		{"f", unitFahrenheit, false},
+		{"kelvin", 0, true},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseTemperatureUnit(test.value)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("parseTemperatureUnit(%q) = %v, %v", test.value, got, err)
+		}
+	}
+}
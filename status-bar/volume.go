@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A way of reading and changing the volume of the default output
+type volumeBackend interface {
+	read() (volumeState, error)
+	set(percent int) error // Every channel to the same volume
+	toggleMute() error
+	subscribe() <-chan struct{} // Nil if changes are only noticed through VOLUME_CHANGED_SIGNAL
+}
+
+// Tried in order until one can read the volume
+var defaultVolumeBackends = []string{"pactl", "amixer", "wpctl"}
+
+var volumeBackends = map[string]volumeBackend{
+	"pactl":  pactlBackend{},
+	"amixer": amixerBackend{},
+	"wpctl":  wpctlBackend{},
+}
+
+var errNoVolumeBackend = errors.New("no volume backend")
+
+// The backend is probed again after this many reads in a row fail, e.g. when PulseAudio was
+// replaced by PipeWire without pipewire-pulse
+const volumeBackendMaxFailures = 3
+
+// Returns the first backend in the list that can read the volume. mixer is the amixer control,
+// Master if empty.
+func probeVolumeBackends(names []string, mixer string) (string, volumeBackend, error) {
+	for _, name := range names {
+		backend, exists := volumeBackends[name]
+		if !exists {
+			logger.Println("Unknown volume backend", name)
+			continue
+		}
+		if amixer, isAmixer := backend.(amixerBackend); isAmixer && mixer != "" {
+			amixer.control = mixer
+			backend = amixer
+		}
+
+		if _, err := backend.read(); err != nil {
+			logger.Println("Volume backend", name, "unavailable", err)
+			continue
+		}
+
+		return name, backend, nil
+	}
+
+	return "", nil, fmt.Errorf("none of the volume backends %v work", names)
+}
+
+// PulseAudio, or PipeWire through pipewire-pulse
+
+type pactlBackend struct{}
+
+// e.g.
+//
+//	Volume: front-left: 42596 /  65% / -11.23 dB,   front-right: 42596 /  65% / -11.23 dB
+//	        balance 0.00
+//	Mute: no
+func parsePactlVolume(volumeOutput, muteOutput []byte) (volumeState, error) {
+	var state volumeState
+
+	percentages := []int{}
+	for _, field := range strings.Fields(string(volumeOutput)) {
+		if !strings.HasSuffix(field, "%") {
+			continue
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(field, "%"))
+		if err != nil {
+			return state, err
+		}
+		percentages = append(percentages, percent)
+	}
+
+	if len(percentages) == 0 {
+		return state, fmt.Errorf("no volume in %q", volumeOutput)
+	}
+
+	mute := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(muteOutput)), "Mute:"))
+	if mute != "yes" && mute != "no" {
+		return state, fmt.Errorf("unexpected mute state %q", muteOutput)
+	}
+
+	state.leftVolume = percentages[0]
+	state.rightVolume = percentages[0]
+	if len(percentages) > 1 {
+		state.rightVolume = percentages[1]
+	}
+	state.leftMuted = mute == "yes"
+	state.rightMuted = state.leftMuted
+
+	return state, nil
+}
+
+func (pactlBackend) read() (volumeState, error) {
+	volumeOutput, err := commandOutput(0, "pactl", "get-sink-volume", "@DEFAULT_SINK@")
+	if err != nil {
+		return volumeState{}, err
+	}
+
+	muteOutput, err := commandOutput(0, "pactl", "get-sink-mute", "@DEFAULT_SINK@")
+	if err != nil {
+		return volumeState{}, err
+	}
+
+	return parsePactlVolume(volumeOutput, muteOutput)
+}
+
+func (pactlBackend) set(percent int) error {
+	_, err := commandOutput(0, "pactl", "set-sink-volume", "@DEFAULT_SINK@", fmt.Sprintf("%d%%", percent))
+	return err
+}
+
+func (pactlBackend) toggleMute() error {
+	_, err := commandOutput(0, "pactl", "set-sink-mute", "@DEFAULT_SINK@", "toggle")
+	return err
+}
+
+func (pactlBackend) subscribe() <-chan struct{} {
+	return subscribeSinkEvents()
+}
+
+// Bare ALSA
+
+type amixerBackend struct {
+	control string // Defaults to Master
+}
+
+func (amixer amixerBackend) controlName() string {
+	if amixer.control == "" {
+		return "Master"
+	}
+	return amixer.control
+}
+
+func (amixer amixerBackend) read() (volumeState, error) {
+	output, err := commandOutput(0, "amixer", "get", amixer.controlName())
+	if err != nil {
+		return volumeState{}, err
+	}
+	return parseAmixerVolume(output)
+}
+
+func (amixer amixerBackend) set(percent int) error {
+	_, err := commandOutput(0, "amixer", "-q", "set", amixer.controlName(), fmt.Sprintf("%d%%", percent))
+	return err
+}
+
+func (amixer amixerBackend) toggleMute() error {
+	_, err := commandOutput(0, "amixer", "-q", "set", amixer.controlName(), "toggle")
+	return err
+}
+
+func (amixerBackend) subscribe() <-chan struct{} {
+	return nil
+}
+
+// PipeWire through WirePlumber
+
+type wpctlBackend struct{}
+
+// e.g. "Volume: 0.55" or "Volume: 0.55 [MUTED]". The volume is a fraction and is the same on
+// every channel.
+func parseWpctlVolume(output []byte) (volumeState, error) {
+	var state volumeState
+
+	fields := strings.Fields(string(output))
+	if len(fields) < 2 || fields[0] != "Volume:" {
+		return state, fmt.Errorf("unexpected wpctl output %q", output)
+	}
+
+	fraction, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return state, err
+	}
+
+	state.leftVolume = int(fraction*100 + 0.5)
+	state.rightVolume = state.leftVolume
+	state.leftMuted = len(fields) > 2 && fields[2] == "[MUTED]"
+	state.rightMuted = state.leftMuted
+
+	return state, nil
+}
+
+func (wpctlBackend) read() (volumeState, error) {
+	output, err := commandOutput(0, "wpctl", "get-volume", "@DEFAULT_AUDIO_SINK@")
+	if err != nil {
+		return volumeState{}, err
+	}
+	return parseWpctlVolume(output)
+}
+
+func (wpctlBackend) set(percent int) error {
+	_, err := commandOutput(0, "wpctl", "set-volume", "@DEFAULT_AUDIO_SINK@", fmt.Sprintf("%d%%", percent))
+	return err
+}
+
+func (wpctlBackend) toggleMute() error {
+	_, err := commandOutput(0, "wpctl", "set-mute", "@DEFAULT_AUDIO_SINK@", "toggle")
+	return err
+}
+
+// wpctl can't watch for changes
+func (wpctlBackend) subscribe() <-chan struct{} {
+	return nil
+}
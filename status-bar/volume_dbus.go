@@ -0,0 +1,84 @@
+//go:build dbus
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// 100% volume in PulseAudio's units
+const pulseVolumeNorm = 65536
+
+// Reads the default sink through PulseAudio's DBus interface (module-dbus-protocol must be loaded)
+func readVolumeDBus() (leftVolume int, leftMuted bool, rightVolume int, rightMuted bool, err error) {
+	session, err := dbus.SessionBus()
+	if err != nil {
+		return
+	}
+
+	// PulseAudio serves its interface on a separate peer to peer bus whose address is on the session bus
+	address, err := session.Object("org.PulseAudio1", "/org/pulseaudio/server_lookup1").GetProperty("org.PulseAudio.ServerLookup1.Address")
+	if err != nil {
+		return
+	}
+
+	addressString, ok := address.Value().(string)
+	if !ok {
+		err = fmt.Errorf("unexpected PulseAudio address %v", address)
+		return
+	}
+
+	connection, err := dbus.Dial(addressString)
+	if err != nil {
+		return
+	}
+	defer connection.Close()
+
+	err = connection.Auth(nil)
+	if err != nil {
+		return
+	}
+
+	sinkPath, err := connection.Object("", "/org/pulseaudio/core1").GetProperty("org.PulseAudio.Core1.FallbackSink")
+	if err != nil {
+		return
+	}
+
+	objectPath, ok := sinkPath.Value().(dbus.ObjectPath)
+	if !ok {
+		err = fmt.Errorf("unexpected fallback sink %v", sinkPath)
+		return
+	}
+	sink := connection.Object("", objectPath)
+
+	volume, err := sink.GetProperty("org.PulseAudio.Core1.Device.Volume")
+	if err != nil {
+		return
+	}
+
+	mute, err := sink.GetProperty("org.PulseAudio.Core1.Device.Mute")
+	if err != nil {
+		return
+	}
+
+	channels, ok := volume.Value().([]uint32)
+	if !ok || len(channels) == 0 {
+		err = fmt.Errorf("unexpected sink volume %v", volume)
+		return
+	}
+	isMuted, _ := mute.Value().(bool)
+
+	toPercent := func(v uint32) int {
+		return int((uint64(v)*100 + pulseVolumeNorm/2) / pulseVolumeNorm)
+	}
+
+	leftVolume = toPercent(channels[0])
+	rightVolume = leftVolume
+	if len(channels) > 1 {
+		rightVolume = toPercent(channels[1])
+	}
+
+	return leftVolume, isMuted, rightVolume, isMuted, nil
+}
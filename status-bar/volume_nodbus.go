@@ -0,0 +1,10 @@
+//go:build !dbus
+
+package main
+
+import "errors"
+
+func readVolumeDBus() (leftVolume int, leftMuted bool, rightVolume int, rightMuted bool, err error) {
+	err = errors.New("built without DBus support, rebuild with -tags dbus")
+	return
+}
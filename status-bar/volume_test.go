@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestParseWpctlVolume(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    volumeState
+		wantErr bool
+	}{
+		{"Volume: 0.55\n", volumeState{leftVolume: 55, rightVolume: 55}, false},
+		{"Volume: 0.55 [MUTED]\n", volumeState{leftVolume: 55, rightVolume: 55, leftMuted: true, rightMuted: true}, false},
+		{"Volume: 0.00\n", volumeState{}, false},
+		{"Volume: 1.00\n", volumeState{leftVolume: 100, rightVolume: 100}, false},
+		{"Volume: 1.50\n", volumeState{leftVolume: 150, rightVolume: 150}, false}, // Boosted past 100%
+		{"Volume: 0.335\n", volumeState{leftVolume: 34, rightVolume: 34}, false},  // Rounded, not cut off
+		{"Volume: 0.29\n", volumeState{leftVolume: 29, rightVolume: 29}, false},   // 0.29*100 is 28.999…
+		{"", volumeState{}, true},
+		{"Volume:\n", volumeState{}, true},
+		{"Volume: loud\n", volumeState{}, true},
+		{"Could not connect to PipeWire\n", volumeState{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseWpctlVolume([]byte(test.output))
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseWpctlVolume(%q) error = %v, want error %v", test.output, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && got != test.want {
+			t.Errorf("parseWpctlVolume(%q) = %+v, want %+v", test.output, got, test.want)
+		}
+	}
+}
+
+// Only the monitor goroutine touches the backend and the value
+type fakeVolumeBackend struct {
+	state volumeState
+}
+
+func (backend *fakeVolumeBackend) read() (volumeState, error) { return backend.state, nil }
+
+func (backend *fakeVolumeBackend) set(percent int) error {
+	backend.state.leftVolume, backend.state.rightVolume = percent, percent
+	return nil
+}
+
+func (backend *fakeVolumeBackend) toggleMute() error {
+	backend.state.leftMuted = !backend.state.leftMuted
+	backend.state.rightMuted = backend.state.leftMuted
+	return nil
+}
+
+func (backend *fakeVolumeBackend) subscribe() <-chan struct{} { return nil }
+
+// Run with -race to check that clicks don't race the monitor or each other
+func TestVolumeClicksRunOnMonitor(t *testing.T) {
+	backend := &fakeVolumeBackend{state: volumeState{leftVolume: 50, rightVolume: 50}}
+	vol := &volumeProvider{backendName: "fake", backend: backend}
+	vol.fetch = vol.readVolume
+
+	changes := make(chan blockChangedMessage)
+	go vol.commandProvider.monitor(changes, 0)
+	go func() {
+		for range changes {
+		}
+	}()
+
+	// The state can only be read safely from the monitor goroutine as well
+	currentVolume := func() volumeState {
+		state := make(chan volumeState)
+		go vol.runOnMonitor(func() { state <- vol.value })
+		return <-state
+	}
+
+	click := func(events ...clickEvent) {
+		// Clicks are handled in their own goroutine, like mainLoop does
+		var wait sync.WaitGroup
+		for _, event := range events {
+			wait.Add(1)
+			go func(event clickEvent) {
+				defer wait.Done()
+				vol.respondToClick(event)
+			}(event)
+		}
+		wait.Wait()
+	}
+
+	// None of the steps are lost
+	clicks := []clickEvent{}
+	for i := 0; i < 10; i++ {
+		clicks = append(clicks, clickEvent{Button: 4, Modifiers: []string{"Shift"}})
+	}
+	click(clicks...)
+	if got, want := currentVolume(), (volumeState{leftVolume: 60, rightVolume: 60}); got != want {
+		t.Errorf("Volume is %+v after scrolling up, want %+v", got, want)
+	}
+
+	click(clickEvent{Button: 5})
+	if got, want := currentVolume(), (volumeState{leftVolume: 55, rightVolume: 55}); got != want {
+		t.Errorf("Volume is %+v after scrolling down, want %+v", got, want)
+	}
+
+	click(clickEvent{Button: 3})
+	if got, want := currentVolume(), (volumeState{leftVolume: 55, leftMuted: true, rightVolume: 55, rightMuted: true}); got != want {
+		t.Errorf("Volume is %+v after muting, want %+v", got, want)
+	}
+}
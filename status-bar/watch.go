@@ -0,0 +1,103 @@
+package main
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB |
+	unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// Watches a file or directory and sends on the returned channel whenever it changes.
+// Events that arrive within coalesceWindow of each other are delivered as a single send, once
+// a whole window passes without any. A path that keeps changing faster than that is only
+// reported once it settles.
+// If the path is deleted or replaced (which is how most editors save) it is watched again
+// as soon as it reappears.
+func watchPath(path string, coalesceWindow time.Duration) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	watchDescriptor, err := unix.InotifyAddWatch(fd, path, watchMask)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	rawEvents := make(chan struct{}, 1)
+	changes := make(chan struct{}, 1)
+
+	notify := func(channel chan struct{}) {
+		select {
+		case channel <- struct{}{}:
+		default: // Already pending
+		}
+	}
+
+	go func() {
+		defer unix.Close(fd)
+		buffer := make([]byte, 4096)
+
+		for {
+			n, err := unix.Read(fd, buffer)
+			if err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				logger.Println("Stopped watching", path, err)
+				return
+			}
+
+			var mask uint32
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+				mask |= event.Mask
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+			}
+
+			if mask&unix.IN_MOVE_SELF != 0 {
+				// The watch follows the moved file, not the path
+				unix.InotifyRmWatch(fd, uint32(watchDescriptor))
+			}
+
+			if mask&(unix.IN_IGNORED|unix.IN_MOVE_SELF) != 0 {
+				for {
+					watchDescriptor, err = unix.InotifyAddWatch(fd, path, watchMask)
+					if err == nil {
+						break
+					}
+					time.Sleep(1 * time.Second)
+				}
+			}
+
+			notify(rawEvents)
+		}
+	}()
+
+	go func() {
+		for range rawEvents {
+			// Wait for the burst to finish before reporting it, i.e. for a whole window without events
+			timer := time.NewTimer(coalesceWindow)
+		coalesce:
+			for {
+				select {
+				case <-rawEvents:
+					if !timer.Stop() {
+						<-timer.C
+					}
+					timer.Reset(coalesceWindow)
+				case <-timer.C:
+					break coalesce
+				}
+			}
+			notify(changes)
+		}
+	}()
+
+	return changes, nil
+}
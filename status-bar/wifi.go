@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const wirelessStatusPath = "/proc/net/wireless"
+
+// Link quality in /proc/net/wireless is out of 70 for most drivers
+const maxLinkQuality = 70
+
+var signalBars = []rune("▁▃▅▇")
+
+type wifiSignalProvider struct {
+	present bool
+	percent int
+
+	// Signal percentages at which another bar is added. One bar is always shown.
+	levelThresholds []int
+}
+
+// Returns the link quality of the first wireless interface as a percentage.
+// The second value is false when there is no wireless interface, e.g. on a wired connection.
+func readWifiSignal() (int, bool) {
+	contents, err := os.ReadFile(wirelessStatusPath)
+	if err != nil {
+		return 0, false
+	}
+
+	// The first two lines are headers:
+	// wlan0: 0000   54.  -56.  -256        0      0      0      0      0        0
+	lines := strings.Split(string(contents), "\n")
+	if len(lines) < 3 {
+		return 0, false
+	}
+
+	for _, line := range lines[2:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		quality, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		if err != nil {
+			continue
+		}
+
+		percent := int(quality * 100 / maxLinkQuality)
+		if percent > 100 {
+			percent = 100
+		}
+		return percent, true
+	}
+
+	return 0, false
+}
+
+func (wifi *wifiSignalProvider) signalLevel() int {
+	level := 1
+	for _, threshold := range wifi.levelThresholds {
+		if wifi.percent >= threshold {
+			level++
+		}
+	}
+	if level > len(signalBars) {
+		level = len(signalBars)
+	}
+	return level
+}
+
+func (wifi *wifiSignalProvider) monitor(changeChan chan<- blockChangedMessage, index int) {
+	for {
+		percent, present := readWifiSignal()
+
+		if percent != wifi.percent || present != wifi.present {
+			oldLevel := wifi.signalLevel()
+			wasPresent := wifi.present
+			wifi.percent, wifi.present = percent, present
+
+			if wifi.signalLevel() != oldLevel || present != wasPresent {
+				changeChan <- blockChangedMessage{
+					index: index,
+				}
+			}
+		}
+
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (wifi *wifiSignalProvider) createBlock() fullSwaybarMessageBodyBlock {
+	var block fullSwaybarMessageBodyBlock
+
+	// Hidden on wired connections
+	if wifi.present {
+		block.FullText = " " + string(signalBars[:wifi.signalLevel()])
+		block.ShortText = ""
+	}
+
+	return block
+}
+
+func (wifi *wifiSignalProvider) name() string {
+	return ""
+}
+
+func (wifi *wifiSignalProvider) respondToClick(event clickEvent) {}
@@ -0,0 +1,3 @@
+module swayipc
+
+go 1.20
@@ -0,0 +1,204 @@
+// Package swayipc talks to sway (or i3) over its IPC socket. Every message, in both directions,
+// is the magic string, the payload length and the message type, both 32 bit and in native byte
+// order, then the payload. It is its own module so that every tool in the repo can use it, see
+// the replace directive in their go.mod.
+package swayipc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Basic messages
+const (
+	IPC_COMMAND   = 0
+	IPC_SUBSCRIBE = 2
+	IPC_SEND_TICK = 10
+	IPC_SYNC      = 11
+)
+
+// Queries
+const (
+	IPC_GET_WORKSPACES    = 1
+	IPC_GET_OUTPUTS       = 3
+	IPC_GET_TREE          = 4
+	IPC_GET_MARKS         = 5
+	IPC_GET_BAR_CONFIG    = 6
+	IPC_GET_VERSION       = 7
+	IPC_GET_BINDING_MODES = 8
+	IPC_GET_CONFIG        = 9
+	IPC_GET_BINDING_STATE = 12
+
+	/* sway-specific command types */
+	IPC_GET_INPUTS = 100
+	IPC_GET_SEATS  = 101
+)
+
+// Events
+const (
+	IPC_EVENT_WORKSPACE        = ((1 << 31) | 0)
+	IPC_EVENT_OUTPUT           = ((1 << 31) | 1)
+	IPC_EVENT_MODE             = ((1 << 31) | 2)
+	IPC_EVENT_WINDOW           = ((1 << 31) | 3)
+	IPC_EVENT_BARCONFIG_UPDATE = ((1 << 31) | 4)
+	IPC_EVENT_BINDING          = ((1 << 31) | 5)
+	IPC_EVENT_SHUTDOWN         = ((1 << 31) | 6)
+	IPC_EVENT_TICK             = ((1 << 31) | 7)
+
+	/* sway-specific event types */
+	IPC_EVENT_BAR_STATE_UPDATE = ((1 << 31) | 20)
+	IPC_EVENT_INPUT            = ((1 << 31) | 21)
+)
+
+const MagicString = "i3-ipc"
+
+const headerSize = len(MagicString) + 8
+
+// Falls back to asking sway (or i3) when SWAYSOCK is missing, e.g. when run from a timer.
+// Cached because a daemon may send many commands.
+var socket struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+func SocketPath() (string, error) {
+	socket.once.Do(func() {
+		for _, variable := range []string{"SWAYSOCK", "I3SOCK"} {
+			if path := os.Getenv(variable); path != "" {
+				socket.path = path
+				return
+			}
+		}
+
+		for _, compositor := range []string{"sway", "i3"} {
+			output, err := exec.Command(compositor, "--get-socketpath").Output()
+			if path := strings.TrimSpace(string(output)); err == nil && path != "" {
+				socket.path = path
+				return
+			}
+		}
+
+		socket.err = errors.New("SWAYSOCK not set and neither sway nor i3 --get-socketpath found a socket")
+	})
+	return socket.path, socket.err
+}
+
+// Returns the payload length from a header. Anything that doesn't start with the magic string
+// means the connection is out of step, so the length would be garbage.
+func ParseHeader(header []byte) (uint32, error) {
+	if len(header) < headerSize || string(header[:len(MagicString)]) != MagicString {
+		return 0, fmt.Errorf("reply header %q doesn't start with %q", header, MagicString)
+	}
+	return binary.LittleEndian.Uint32(header[len(MagicString):]), nil
+}
+
+// Writes the header and the payload with a single write
+func WriteMessage(writer io.Writer, msgType uint32, payload []byte) error {
+	message := make([]byte, headerSize, headerSize+len(payload))
+	copy(message, MagicString)
+	binary.LittleEndian.PutUint32(message[len(MagicString):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(message[len(MagicString)+4:], msgType)
+	message = append(message, payload...)
+
+	_, err := writer.Write(message)
+	return err
+}
+
+// Reads one whole message, however the socket splits it up
+func ReadMessage(reader io.Reader) (msgType uint32, payload []byte, err error) {
+	header := make([]byte, headerSize)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, err := ParseHeader(header)
+	if err != nil {
+		return 0, nil, err
+	}
+	msgType = binary.LittleEndian.Uint32(header[len(MagicString)+4:])
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err == io.EOF {
+		// The header promised a payload
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return msgType, payload, nil
+}
+
+// One connection to sway. Replies come back in the order that messages were sent, so a Client
+// must not be used by more than one goroutine at a time.
+type Client struct {
+	connection net.Conn
+}
+
+func (client *Client) Dial() error {
+	socketPath, err := SocketPath()
+	if err != nil {
+		return err
+	}
+
+	client.connection, err = net.Dial("unix", socketPath)
+	return err
+}
+
+// Sends a message and returns the payload of the reply
+func (client *Client) Send(msgType int, payload string) ([]byte, error) {
+	if client.connection == nil {
+		return nil, errors.New("not connected to sway")
+	}
+
+	err := WriteMessage(client.connection, uint32(msgType), []byte(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	_, reply, err := ReadMessage(client.connection)
+	return reply, err
+}
+
+// After this the client only receives the given events, e.g. "output", with ReadEvent
+func (client *Client) Subscribe(events ...string) error {
+	payload, _ := json.Marshal(events)
+	reply, err := client.Send(IPC_SUBSCRIBE, string(payload))
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if json.Unmarshal(reply, &result) != nil || !result.Success {
+		return fmt.Errorf("sway refused the subscription to %v: %s", events, reply)
+	}
+	return nil
+}
+
+// Waits for the next event of a subscribed client and returns its type, e.g. IPC_EVENT_WINDOW,
+// and payload
+func (client *Client) ReadEvent() (uint32, []byte, error) {
+	if client.connection == nil {
+		return 0, nil, errors.New("not connected to sway")
+	}
+	return ReadMessage(client.connection)
+}
+
+func (client *Client) Close() error {
+	if client.connection == nil {
+		return nil
+	}
+	return client.connection.Close()
+}
@@ -0,0 +1,236 @@
+package swayipc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Both ends of a connected Unix socket, like the one sway listens on
+func socketPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connections := [2]net.Conn{}
+	for i, fd := range fds {
+		file := os.NewFile(uintptr(fd), "swayipc-test")
+		connection, err := net.FileConn(file)
+		file.Close() // FileConn has its own copy
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { connection.Close() })
+		connections[i] = connection
+	}
+	return connections[0], connections[1]
+}
+
+func header(magic string, length, msgType uint32) []byte {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.LittleEndian.PutUint32(header[len(MagicString):], length)
+	binary.LittleEndian.PutUint32(header[len(MagicString)+4:], msgType)
+	return header
+}
+
+func TestWriteReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		msgType uint32
+		payload []byte
+	}{
+		{"empty", IPC_GET_OUTPUTS, []byte{}},
+		{"command", IPC_COMMAND, []byte(`output "DP-1" bg "wallpaper.png" fill`)},
+		{"event", IPC_EVENT_WINDOW, []byte(`{"change":"focus"}`)},
+		// More than the socket buffer, so it can't be written or read in one go
+		{"large", IPC_GET_TREE, bytes.Repeat([]byte("0123456789abcdef"), 1<<16)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writer, reader := socketPair(t)
+
+			written := make(chan error, 1)
+			go func() { written <- WriteMessage(writer, test.msgType, test.payload) }()
+
+			msgType, payload, err := ReadMessage(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msgType != test.msgType || !bytes.Equal(payload, test.payload) {
+				t.Errorf("Read type %d and %d bytes, want type %d and %d bytes", msgType, len(payload), test.msgType, len(test.payload))
+			}
+			if err := <-written; err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWriteMessageFraming(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteMessage(&buffer, IPC_SUBSCRIBE, []byte(`["output"]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := append(header(MagicString, 10, IPC_SUBSCRIBE), `["output"]`...)
+	if !bytes.Equal(buffer.Bytes(), want) {
+		t.Errorf("Wrote %q, want %q", buffer.Bytes(), want)
+	}
+}
+
+// Messages can arrive in any number of pieces, including a header split across reads
+func TestReadMessageSplitReads(t *testing.T) {
+	payload := []byte(`{"success":true}`)
+	message := append(header(MagicString, uint32(len(payload)), IPC_COMMAND), payload...)
+	// Then the start of the next message, which must be left alone
+	message = append(message, header(MagicString, 2, IPC_COMMAND)...)
+	message = append(message, "{}"...)
+
+	for _, pieceSize := range []int{1, 3, headerSize - 1, headerSize, headerSize + 1} {
+		writer, reader := socketPair(t)
+
+		go func(pieceSize int) {
+			for start := 0; start < len(message); start += pieceSize {
+				end := start + pieceSize
+				if end > len(message) {
+					end = len(message)
+				}
+				if _, err := writer.Write(message[start:end]); err != nil {
+					return
+				}
+				// Gives the reader a chance to see each piece on its own
+				time.Sleep(time.Millisecond)
+			}
+		}(pieceSize)
+
+		for _, want := range []string{`{"success":true}`, "{}"} {
+			msgType, got, err := ReadMessage(reader)
+			if err != nil {
+				t.Fatalf("Pieces of %d: %v", pieceSize, err)
+			}
+			if msgType != IPC_COMMAND || string(got) != want {
+				t.Errorf("Pieces of %d: read type %d and %q, want %q", pieceSize, msgType, got, want)
+			}
+		}
+	}
+}
+
+// The connection closing part of the way through is an error rather than a short message
+func TestReadMessageTruncated(t *testing.T) {
+	message := append(header(MagicString, 16, IPC_COMMAND), `{"success"`...)
+
+	for _, length := range []int{0, 5, headerSize, len(message)} {
+		writer, reader := socketPair(t)
+		writer.Write(message[:length])
+		writer.Close()
+
+		_, payload, err := ReadMessage(reader)
+		if length == 0 && err != io.EOF {
+			t.Errorf("Empty connection: got error %v, want EOF", err)
+		} else if length > 0 && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Closed after %d bytes: read %q with error %v", length, payload, err)
+		}
+	}
+}
+
+// Followed by a whole payload, so that only the magic string is wrong
+func TestReadMessageWrongMagic(t *testing.T) {
+	for _, magic := range []string{"i3-ipx", "I3-IPC", "\x00\x00\x00\x00\x00\x00"} {
+		message := append(header(magic, 2, IPC_COMMAND), "{}"...)
+
+		_, payload, err := ReadMessage(bytes.NewReader(message))
+		if err == nil || !strings.Contains(err.Error(), "doesn't start with") {
+			t.Errorf("%q: read %q with error %v", magic, payload, err)
+		}
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	length, err := ParseHeader(header(MagicString, 42, IPC_COMMAND))
+	if err != nil || length != 42 {
+		t.Errorf("Got %d, %v, want 42", length, err)
+	}
+
+	if _, err := ParseHeader([]byte(MagicString)); err == nil {
+		t.Error("Expected an error for a short header")
+	}
+}
+
+// Answers each message with the reply for its type, after checking what the client sent
+func serveReplies(t *testing.T, connection net.Conn, replies map[uint32]string) {
+	go func() {
+		for {
+			msgType, payload, err := ReadMessage(connection)
+			if err != nil {
+				return
+			}
+			if msgType == IPC_SUBSCRIBE && string(payload) != `["output","window"]` {
+				t.Errorf("Subscribed with %q", payload)
+			}
+
+			if err := WriteMessage(connection, msgType, []byte(replies[msgType])); err != nil {
+				return
+			}
+			if msgType == IPC_SUBSCRIBE {
+				WriteMessage(connection, IPC_EVENT_OUTPUT, []byte(`{"change":"unspecified"}`))
+			}
+		}
+	}()
+}
+
+func TestClient(t *testing.T) {
+	clientEnd, swayEnd := socketPair(t)
+	serveReplies(t, swayEnd, map[uint32]string{
+		IPC_GET_VERSION: `{"major":1}`,
+		IPC_SUBSCRIBE:   `{"success":true}`,
+	})
+	client := Client{connection: clientEnd}
+
+	reply, err := client.Send(IPC_GET_VERSION, "")
+	if err != nil || string(reply) != `{"major":1}` {
+		t.Fatalf("Got reply %q, %v", reply, err)
+	}
+
+	if err := client.Subscribe("output", "window"); err != nil {
+		t.Fatal(err)
+	}
+	eventType, event, err := client.ReadEvent()
+	if err != nil || eventType != IPC_EVENT_OUTPUT || string(event) != `{"change":"unspecified"}` {
+		t.Errorf("Got event %d %q, %v", eventType, event, err)
+	}
+}
+
+func TestClientSubscribeRefused(t *testing.T) {
+	clientEnd, swayEnd := socketPair(t)
+	serveReplies(t, swayEnd, map[uint32]string{IPC_SUBSCRIBE: `{"success":false}`})
+	client := Client{connection: clientEnd}
+
+	if err := client.Subscribe("output", "window"); err == nil {
+		t.Error("Expected an error when sway refuses the subscription")
+	}
+}
+
+func TestClientNotConnected(t *testing.T) {
+	var client Client
+	if _, err := client.Send(IPC_GET_VERSION, ""); err == nil {
+		t.Error("Send didn't fail without a connection")
+	}
+	if _, _, err := client.ReadEvent(); err == nil {
+		t.Error("ReadEvent didn't fail without a connection")
+	}
+	if err := client.Close(); err != nil {
+		t.Error("Close failed without a connection", err)
+	}
+}